@@ -5,6 +5,7 @@ go 1.20
 require (
 	github.com/hashicorp/go-hclog v1.5.0
 	github.com/hashicorp/go-plugin v1.4.9
+	github.com/mrcook/go-plugin-examples/discovery v0.0.0
 )
 
 require (
@@ -23,3 +24,5 @@ require (
 	google.golang.org/grpc v1.54.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
 )
+
+replace github.com/mrcook/go-plugin-examples/discovery => ../discovery
@@ -1,4 +1,4 @@
-// A basic plugin example of type Greeter.
+// A basic plugin example of type ContextGreeter.
 //
 // Note: all logging requests from this plugin will be made via the host
 // application itself. If the host application disables logging, then these
@@ -10,6 +10,8 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
 
 	"github.com/hashicorp/go-hclog"
@@ -19,19 +21,24 @@ import (
 )
 
 // HelloGreeterPlugin is our custom plugin: it's a real implementation of the
-// Greeter plugin type.
+// ContextGreeter plugin type.
 type HelloGreeterPlugin struct {
 	logger hclog.Logger
 }
 
 // Greet is the message we wish to return from our custom plugin.
-func (plugin *HelloGreeterPlugin) Greet() string {
-	msg := "Hello!"
+// If the host application deadline has already passed, no greeting is made.
+func (plugin *HelloGreeterPlugin) Greet(ctx context.Context, name string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	msg := fmt.Sprintf("Hello, %s!", name)
 
 	// this log message will be sent to the host application.
 	plugin.logger.Debug("HelloGreeterPlugin.Greet", "greeting", msg)
 
-	return msg
+	return msg, nil
 }
 
 // go-plugin's are normal Go applications so require a main entry point.
@@ -45,15 +52,18 @@ func main() {
 	// this log message will be sent to the host application.
 	greeter.logger.Debug("HelloGreeterPlugin main() function")
 
-	// Assign our plugin as the required plugin type.
-	var plugins = plugin.PluginSet{
-		sdk.GreeterPluginName: &sdk.GreeterPlugin{Impl: greeter},
+	// Assign our plugin as the required plugin type, under the protocol
+	// version for the ContextGreeter.
+	versionedPlugins := map[int]plugin.PluginSet{
+		sdk.ContextGreeterProtocolVersion: {
+			sdk.GreeterPluginName: &sdk.ContextGreeterPlugin{Impl: greeter},
+		},
 	}
 
 	// start listening for incoming RPC requests.
 	plugin.Serve(&plugin.ServeConfig{
-		HandshakeConfig: sdk.HandshakeConfig,
-		Plugins:         plugins,
+		HandshakeConfig:  sdk.HandshakeConfig,
+		VersionedPlugins: versionedPlugins,
 	})
 }
 
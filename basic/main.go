@@ -8,33 +8,73 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 
 	"github.com/mrcook/go-plugin-examples/basic/sdk"
+	"github.com/mrcook/go-plugin-examples/discovery"
 )
 
+// Greeter plugin executables are named "<name>_plugin", e.g. "hello_plugin".
+const pluginPattern = "*_plugin"
+
 func main() {
-	// The set of plugins that our host application supports.
-	pluginMap := plugin.PluginSet{
-		sdk.GreeterPluginName: &sdk.GreeterPlugin{},
+	name := flag.String("name", "World", "Name of the person to greet.")
+	timeout := flag.Duration("timeout", 5*time.Second, "How long to wait for the greeting.")
+	pluginName := flag.String("plugin-name", "hello", "Name of the greeter plugin to use.")
+	pluginDirs := flag.String("plugin-dir", ".", "Directories to search for plugins, separated by the OS path list separator.")
+	flag.Parse()
+
+	// Find the requested plugin in the plugin directories. Only plugins with
+	// a manifest are used, and the executable must match its checksum.
+	registry, err := discovery.Discover(discovery.Config{
+		Dirs:             discovery.SplitDirs(*pluginDirs),
+		Pattern:          pluginPattern,
+		ProtocolVersions: []int{sdk.GreeterProtocolVersion, sdk.ContextGreeterProtocolVersion},
+		RequireManifest:  true,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	greeterPlugin, err := registry.Lookup(*pluginName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := greeterPlugin.Verify(); err != nil {
+		log.Fatal(err)
+	}
+
+	// The set of plugins that our host application supports, for each
+	// protocol version. Version 1 plugins are still supported, and the newest
+	// version supported by both the host and plugin will be used.
+	versionedPlugins := map[int]plugin.PluginSet{
+		sdk.GreeterProtocolVersion: {
+			sdk.GreeterPluginName: &sdk.GreeterPlugin{},
+		},
+		sdk.ContextGreeterProtocolVersion: {
+			sdk.GreeterPluginName: &sdk.ContextGreeterPlugin{},
+		},
 	}
 
 	// Configure a new plugin client:
 	// - HandshakeConfig: is required
-	// - Plugins: is a map containing the name of your plugin and its plugin.Plugin implementation
-	// - Cmd: points to the compiled binary of your plugin
+	// - VersionedPlugins: is a map of protocol versions, containing the name of your plugin and its plugin.Plugin implementation
+	// - Cmd: points to the compiled binary of your plugin, as found by discovery
+	// - SecureConfig: verifies the binary checksum again, just before it is launched
 	// - Logger: (optional) used for logging from both the host application and your plugin (if configured)
 	pluginClient := plugin.NewClient(&plugin.ClientConfig{
-		HandshakeConfig: sdk.HandshakeConfig,
-		Plugins:         pluginMap,
-		Cmd:             exec.Command("./hello_plugin"),
-		Logger:          logger(),
+		HandshakeConfig:  sdk.HandshakeConfig,
+		VersionedPlugins: versionedPlugins,
+		Cmd:              greeterPlugin.Cmd(),
+		SecureConfig:     greeterPlugin.SecureConfig(),
+		Logger:           logger(),
 	})
 	defer pluginClient.Kill()
 
@@ -51,13 +91,30 @@ func main() {
 	}
 
 	// As Dispense() returns an interface, we need to cast it to the plugin
-	// type supported by the host application, which in our case is a Greeter.
+	// type supported by the host application, which in our case is a
+	// ContextGreeter. Version 1 plugins return a Greeter, so these are wrapped
+	// with the compatibility shim.
 	// This feels like a normal interface implementation, but is in fact
 	// communicating over an net/rpc connection.
-	greeter := raw.(sdk.Greeter)
+	var greeter sdk.ContextGreeter
+	switch g := raw.(type) {
+	case sdk.ContextGreeter:
+		greeter = g
+	case sdk.Greeter:
+		greeter = sdk.NewGreeterShim(g)
+	default:
+		log.Fatalf("unsupported greeter plugin: %T", raw)
+	}
+
+	// The deadline is sent along with the request to the plugin.
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
 
 	// Let's see what greeting the plugin returns!
-	greeting := greeter.Greet()
+	greeting, err := greeter.Greet(ctx, *name)
+	if err != nil {
+		log.Fatal(err)
+	}
 	fmt.Printf("\n\nThe plugin greeting is: %s\n\n\n", greeting)
 }
 
@@ -6,15 +6,23 @@ package sdk
 
 import "github.com/hashicorp/go-plugin"
 
+// The protocol versions supported by the Greeter plugin types.
+// - version 1: the original Greeter interface
+// - version 2: the ContextGreeter interface, with context and error support
+const (
+	GreeterProtocolVersion        = 1
+	ContextGreeterProtocolVersion = 2
+)
+
 // HandshakeConfig is used to perform a basic handshake between a plugin and
 // host. If the handshake fails, a user friendly error is shown.
 // This prevents users from executing bad plugins or executing a plugin
 // directory. It is a UX feature, not a security feature.
 var HandshakeConfig = plugin.HandshakeConfig{
-	// In general it is better to omit ProtocolVersion and explicitly set
-	// VersionedPlugins in Client/Server configurations.
-	// For simplicity, ProtocolVersion is used in this example.
-	ProtocolVersion: 1,
+	// Plugins written before the ContextGreeter was introduced only set
+	// `Plugins` in their ServeConfig, which go-plugin serves under this
+	// ProtocolVersion. Newer plugins and hosts should set VersionedPlugins.
+	ProtocolVersion: GreeterProtocolVersion,
 
 	// Once set, these magic cookie values should NEVER be changed.
 	MagicCookieKey:   "BASIC_PLUGIN", // a unique key for your application
@@ -0,0 +1,128 @@
+// Copyright (c) Michael R. Cook.
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"net/rpc"
+	"time"
+
+	"github.com/hashicorp/go-plugin"
+)
+
+// ContextGreeter is the version 2 interface of the Greeter plugin type.
+// Unlike Greeter, it accepts a context so that the host application can set a
+// deadline on the request, and any RPC failures are returned as errors.
+type ContextGreeter interface {
+	Greet(ctx context.Context, name string) (string, error)
+}
+
+// ContextGreeterPlugin is the implementation of plugin.Plugin used to serve
+// and consume net/rpc plugins of type ContextGreeter.
+//
+// It uses the same GreeterPluginName as the GreeterPlugin, but must be
+// assigned to the ContextGreeterProtocolVersion in the `VersionedPlugins`.
+type ContextGreeterPlugin struct {
+	Impl ContextGreeter
+}
+
+// Server must return an RPC server for this plugin type.
+func (p *ContextGreeterPlugin) Server(_ *plugin.MuxBroker) (interface{}, error) {
+	return &contextGreeterServer{Impl: p.Impl}, nil
+}
+
+// Client must return an implementation of our interface that communicates over
+// an RPC client.
+func (_ *ContextGreeterPlugin) Client(_ *plugin.MuxBroker, c *rpc.Client) (interface{}, error) {
+	return &contextGreeterClient{client: c}, nil
+}
+
+// GreetRequest are the arguments sent with a ContextGreeter.Greet request.
+// Using a struct, rather than a map, allows net/rpc to check the types.
+type GreetRequest struct {
+	Name string
+
+	// Deadline is taken from the host application context, and is the zero
+	// value when no deadline has been set.
+	Deadline time.Time
+}
+
+// GreetResponse is the reply to a ContextGreeter.Greet request.
+type GreetResponse struct {
+	Greeting string
+}
+
+// contextGreeterClient is a client implementation that talks over RPC.
+type contextGreeterClient struct{ client *rpc.Client }
+
+func (g *contextGreeterClient) Greet(ctx context.Context, name string) (string, error) {
+	req := &GreetRequest{Name: name}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Deadline = deadline
+	}
+
+	resp := &GreetResponse{}
+	call := g.client.Go("Plugin.Greet", req, resp, nil)
+
+	// net/rpc has no way to cancel an in-flight request, so stop waiting for
+	// the response once the context is done. The plugin will also give up
+	// once the deadline sent in the request has passed.
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-call.Done:
+		if call.Error != nil {
+			return "", call.Error
+		}
+		return resp.Greeting, nil
+	}
+}
+
+// contextGreeterServer is the RPC server that contextGreeterClient talks to,
+// conforming to the requirements of net/rpc.
+type contextGreeterServer struct {
+	Impl ContextGreeter
+}
+
+func (s *contextGreeterServer) Greet(req *GreetRequest, resp *GreetResponse) error {
+	ctx := context.Background()
+	if !req.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, req.Deadline)
+		defer cancel()
+	}
+
+	greeting, err := s.Impl.Greet(ctx, req.Name)
+	if err != nil {
+		return err
+	}
+	resp.Greeting = greeting
+	return nil
+}
+
+// NewGreeterShim wraps a version 1 Greeter so that host applications only
+// need to work with the ContextGreeter interface.
+//
+// Version 1 plugins do not accept a name, so it is ignored. When the Greeter
+// was dispensed from a plugin, the shim stops waiting once the context is
+// done, and returns RPC failures as errors rather than panicking.
+func NewGreeterShim(g Greeter) ContextGreeter {
+	return &greeterShim{greeter: g}
+}
+
+// greeterShim is the ContextGreeter returned by NewGreeterShim.
+type greeterShim struct {
+	greeter Greeter
+}
+
+func (s *greeterShim) Greet(ctx context.Context, _ string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if c, ok := s.greeter.(*greeterClient); ok {
+		return c.greet(ctx)
+	}
+	return s.greeter.Greet(), nil
+}
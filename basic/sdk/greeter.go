@@ -10,6 +10,7 @@
 package sdk
 
 import (
+	"context"
 	"net/rpc"
 
 	"github.com/hashicorp/go-plugin"
@@ -17,8 +18,11 @@ import (
 
 // Greeter is the interface that we're exposing as a plugin.
 // Any plugin that wishes to act as a Greeter plugin must implement this interface.
+//
+// Greeter is served under protocol version 1 and is kept so that existing
+// plugins continue to load. New plugins should implement ContextGreeter.
 type Greeter interface {
-	Greet() string // TODO: add error on return? (see panic message in `Greet()` below)
+	Greet() string
 }
 
 // GreeterPluginName is an important variable.
@@ -55,20 +59,34 @@ func (_ *GreeterPlugin) Client(_ *plugin.MuxBroker, c *rpc.Client) (interface{},
 type greeterClient struct{ client *rpc.Client }
 
 func (g *greeterClient) Greet() string {
-	var resp string
-
-	// `Plugin`: a go-plugin hardcoded value
-	// `Greet` the method as defined on the Greeter plugin interface
-	err := g.client.Call("Plugin.Greet", new(interface{}), &resp)
+	resp, err := g.greet(context.Background())
 	if err != nil {
 		// You usually want your interfaces to return errors,
 		// if they don't, there isn't much other choice here.
+		// Use NewGreeterShim to receive these errors instead.
 		panic(err)
 	}
 
 	return resp
 }
 
+// greet makes the RPC request, returning early if the context is done before
+// the plugin responds.
+func (g *greeterClient) greet(ctx context.Context) (string, error) {
+	var resp string
+
+	// `Plugin`: a go-plugin hardcoded value
+	// `Greet` the method as defined on the Greeter plugin interface
+	call := g.client.Go("Plugin.Greet", new(interface{}), &resp, nil)
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-call.Done:
+		return resp, call.Error
+	}
+}
+
 // GreeterServer is the RPC server that GreeterRpcClient talks to,
 // conforming to the requirements of net/rpc.
 type greeterServer struct {
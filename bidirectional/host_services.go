@@ -0,0 +1,143 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/hashicorp/go-hclog"
+
+	"github.com/mrcook/go-plugin-examples/bidirectional/sdk"
+	kvsdk "github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// newHostServices returns the services offered to the named plugin. The
+// messages and metrics of the plugin are recorded along with its name.
+func newHostServices(pluginName string, args cliArgs, storage kvsdk.KVStore) *sdk.HostServices {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   "counter",
+		Output: os.Stderr,
+		Level:  args.logLevel,
+	}).With("plugin", pluginName)
+
+	services := &sdk.HostServices{
+		Logger:  logger,
+		Config:  args.config,
+		Metrics: &hostMetrics{pluginName: pluginName, logger: logger},
+	}
+	if args.policy != nil {
+		services.Policy = args.policy
+	}
+	if storage != nil {
+		services.Storage = storage
+	}
+	return services
+}
+
+// hostMetrics is the MetricsEmitter offered to a plugin, which adds the
+// values emitted to the metrics totals.
+type hostMetrics struct {
+	pluginName string
+	logger     hclog.Logger
+}
+
+func (m *hostMetrics) Emit(_ context.Context, name string, value float64, labels map[string]string) error {
+	m.logger.Debug("metric", "name", name, "value", value, "labels", labels)
+	metrics.add(m.pluginName, name, value)
+	return nil
+}
+
+// metrics holds the totals of the metrics emitted by all plugins, which are
+// printed by the stats command of the REPL.
+var metrics = &metricTotals{totals: make(map[metricKey]*metricTotal)}
+
+type metricKey struct {
+	pluginName string
+	name       string
+}
+
+type metricTotal struct {
+	count int
+	sum   float64
+}
+
+type metricTotals struct {
+	mu     sync.Mutex
+	totals map[metricKey]*metricTotal
+}
+
+func (t *metricTotals) add(pluginName, name string, value float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := metricKey{pluginName: pluginName, name: name}
+	total, ok := t.totals[key]
+	if !ok {
+		total = &metricTotal{}
+		t.totals[key] = total
+	}
+	total.count++
+	total.sum += value
+}
+
+// write prints the metric totals as a table, sorted by plugin and metric name.
+func (t *metricTotals) write(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := make([]metricKey, 0, len(t.totals))
+	for key := range t.totals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].pluginName != keys[j].pluginName {
+			return keys[i].pluginName < keys[j].pluginName
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PLUGIN\tMETRIC\tCOUNT\tSUM")
+	for _, key := range keys {
+		total := t.totals[key]
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%g\n", key.pluginName, key.name, total.count, total.sum)
+	}
+	tw.Flush()
+}
+
+// configFlag collects the repeated --config flags into a MapConfig.
+type configFlag struct {
+	config sdk.MapConfig
+}
+
+func (f *configFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	var pairs []string
+	for key, value := range f.config {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f *configFlag) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || len(key) == 0 {
+		return fmt.Errorf("must be given as key=value")
+	}
+	if f.config == nil {
+		f.config = sdk.MapConfig{}
+	}
+	f.config[key] = value
+	return nil
+}
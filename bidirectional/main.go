@@ -6,80 +6,166 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
-	"os/exec"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 
 	"github.com/mrcook/go-plugin-examples/bidirectional/sdk"
+	"github.com/mrcook/go-plugin-examples/discovery"
+	kvsdk "github.com/mrcook/go-plugin-examples/grpc/sdk"
 )
 
+// Plugin executables are named "counter-<name>", e.g. "counter-go-grpc", and
+// are found in the plugin directories by discovery.
+const pluginPattern = "counter-*"
+
 func main() {
 	// Fetch the command, key, and value from the CLI args.
 	args := parseFlags()
 
-	// A map of the plugins we can dispense.
+	// Start the KVStore plugin offered to the counter plugin as its storage,
+	// when requested.
+	var storage kvsdk.KVStore
+	if len(args.storageName) > 0 {
+		storageClient, kv, err := startStorage(args.storageName, args)
+		if err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+		defer storageClient.Kill()
+		storage = kv
+	}
+
+	// Start the requested plugin.
+	pluginClient, counter, err := startPlugin(args.pluginName, args, storage)
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		os.Exit(1)
+	}
+	defer pluginClient.Kill()
+
+	// The repl command reads commands until the user exits, and may switch
+	// to another plugin, so it stops the plugin itself.
+	if args.command == "repl" {
+		if err := runREPL(pluginClient, counter, storage, args); err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The deadline is sent along with the request to the plugin, which passes
+	// it back to the host when calling the AddHelper.
+	ctx, cancel := context.WithTimeout(context.Background(), args.timeout)
+	defer cancel()
+
+	// Call the appropriate method based on that requested by the user.
+	if args.command == "get" {
+		result, err := counter.GetContext(ctx, args.key)
+		if err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+		// Let's see what the plugin returns!
+		fmt.Println(result)
+	} else if args.command == "put" {
+		// Provide our trusted helper for doing the summation work.
+		err = counter.PutContext(ctx, args.key, args.value, &hostAddHelper{})
+		if err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+	} else if args.command == "increment" {
+		result, err := counter.IncrementContext(ctx, args.key, args.value)
+		if err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(result)
+	} else if args.command == "cas" {
+		swapped, err := counter.CompareAndSwapContext(ctx, args.key, args.value, args.newValue)
+		if err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(swapped)
+	}
+}
+
+// startPlugin finds the named plugin in the plugin directories, and starts it.
+// The storage is offered to the plugin when not nil.
+func startPlugin(pluginName string, args cliArgs, storage kvsdk.KVStore) (*plugin.Client, sdk.ContextCounterStore, error) {
+	// Find the requested plugin in the plugin directories. Only plugins with
+	// a manifest are used, and the executable must match its checksum.
+	registry, err := discovery.Discover(discovery.Config{
+		Dirs:             discovery.SplitDirs(args.pluginDirs),
+		Pattern:          pluginPattern,
+		ProtocolVersions: []int{int(sdk.HandshakeConfig.ProtocolVersion)},
+		RequireManifest:  true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	counterPlugin, err := registry.Lookup(pluginName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := counterPlugin.Verify(); err != nil {
+		return nil, nil, err
+	}
+
+	// A map of the plugins we can dispense. The host services are offered to
+	// the plugin when it is dispensed.
 	pluginMap := plugin.PluginSet{
-		sdk.CounterPluginName: &sdk.CounterPlugin{},
+		sdk.CounterPluginName: &sdk.CounterPlugin{
+			HostServices: newHostServices(pluginName, args, storage),
+		},
 	}
 
 	// Configure a new plugin client:
 	// - HandshakeConfig: is required
 	// - Plugins: is a map containing the supported plugins and their plugin.Plugin implementations
 	// - Cmd: points to the compiled binary of your plugin
+	// - SecureConfig: verifies the binary checksum again, just before it is launched
 	// - AllowedProtocols: by default only net/rpc is allowed, so add gRPC support
 	// - Logger: configured to discard all logs
 	pluginClient := plugin.NewClient(&plugin.ClientConfig{
 		HandshakeConfig:  sdk.HandshakeConfig,
 		Plugins:          pluginMap,
-		Cmd:              exec.Command("sh", "-c", "./counter-go-grpc"),
+		Cmd:              counterPlugin.Cmd(),
+		SecureConfig:     counterPlugin.SecureConfig(),
 		AllowedProtocols: []plugin.Protocol{plugin.ProtocolNetRPC, plugin.ProtocolGRPC},
 		Logger:           logger(),
 	})
-	defer pluginClient.Kill()
 
 	// Get the client for RPC communication.
 	client, err := pluginClient.Client()
 	if err != nil {
-		fmt.Println("Error:", err.Error())
-		os.Exit(1)
+		pluginClient.Kill()
+		return nil, nil, err
 	}
 
 	// Request the plugin.
 	raw, err := client.Dispense(sdk.CounterPluginName)
 	if err != nil {
-		fmt.Println("Error:", err.Error())
-		os.Exit(1)
+		pluginClient.Kill()
+		return nil, nil, err
 	}
 
 	// As Dispense() returns an interface, we need to cast it to the plugin
 	// type supported by the host application, which in our case is a counter store.
 	// This feels like a normal interface implementation, but is in fact
 	// communicating over an RPC connection.
-	counter := raw.(sdk.CounterStore)
-
-	// Call the appropriate method based on that requested by the user.
-	if args.command == "get" {
-		result, err := counter.Get(args.key)
-		if err != nil {
-			fmt.Println("Error:", err.Error())
-			os.Exit(1)
-		}
-		// Let's see what the plugin returns!
-		fmt.Println(result)
-	} else if args.command == "put" {
-		// Provide our trusted helper for doing the summation work.
-		err = counter.Put(args.key, args.value, &hostAddHelper{})
-		if err != nil {
-			fmt.Println("Error:", err.Error())
-			os.Exit(1)
-		}
-	}
+	// The context-aware variant is used so a hung plugin can't block forever.
+	return pluginClient, raw.(sdk.ContextCounterStore), nil
 }
 
 // As we're not trusting plugins to do the summation work, add our own
@@ -90,30 +176,68 @@ func (*hostAddHelper) Sum(a, b int64) (int64, error) {
 	return a + b, nil
 }
 
+func (h *hostAddHelper) SumContext(ctx context.Context, a, b int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return h.Sum(a, b)
+}
+
 // Contains all the data required to run the application.
 type cliArgs struct {
-	command string // get or put command
-	key     string // filename key
-	value   int64  // value to be added
+	pluginName  string           // the discovered plugin to use
+	storageName string           // the discovered KVStore plugin offered as storage
+	pluginDirs  string           // directories to search for plugins
+	timeout     time.Duration    // how long to wait for the plugin to respond
+	logLevel    hclog.Level      // level of the messages logged by plugins
+	config      sdk.MapConfig    // configuration offered to plugins
+	policy      *sdk.RangePolicy // range of the numbers plugins may store, if limited
+	command     string           // get, put, increment, cas, or repl command
+	key         string           // filename key
+	value       int64            // value to be added, or the old value for cas
+	newValue    int64            // new value for cas
 }
 
 func parseFlags() cliArgs {
+	pluginName := flag.String("plugin-name", "go-grpc", "Name of the discovered plugin to use.")
+	storageName := flag.String("storage", "", "Name of a discovered KVStore plugin, e.g. go-grpc, offered to the plugin as its storage.")
+	pluginDirs := flag.String("plugin-dir", ".", "Directories to search for plugins, separated by the OS path list separator.")
+	timeout := flag.Duration("timeout", 5*time.Second, "How long to wait for the plugin to respond.")
+	logLevel := flag.String("log-level", "warn", "Level of the plugin messages to log to stderr: trace, debug, info, warn, error, or off.")
+	var config configFlag
+	flag.Var(&config, "config", "Configuration offered to plugins, as key=value. May be repeated.")
+	minValue := flag.String("min", "", "Smallest number plugins may store, e.g. 0 for non-negative counters.")
+	maxValue := flag.String("max", "", "Largest number plugins may store.")
 	flag.Parse()
 
+	level := hclog.LevelFromString(*logLevel)
+	if level == hclog.NoLevel {
+		fmt.Printf("invalid log level '%s'\n", *logLevel)
+		os.Exit(1)
+	}
+
+	policy, err := rangePolicy(*minValue, *maxValue)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
 	command := flag.Arg(0)
-	if command != "get" && command != "put" {
-		fmt.Printf("invalid command, must be 'get' or 'put', given '%s'\n", command)
+	switch command {
+	case "get", "put", "increment", "cas", "repl":
+	default:
+		fmt.Printf("invalid command, must be 'get', 'put', 'increment', 'cas', or 'repl', given '%s'\n", command)
 		os.Exit(1)
 	}
 
-	var numberToAdd int64
+	var numberToAdd, newNumber int64
 
 	key := flag.Arg(1)
 	value := flag.Arg(2)
-	if len(key) == 0 {
+	if len(key) == 0 && command != "repl" {
 		fmt.Println("key must be present")
 		os.Exit(1)
-	} else if command == "put" {
+	} else if command == "put" || command == "increment" || command == "cas" {
 		if i, err := strconv.Atoi(value); err != nil {
 			fmt.Println("value does not seem to be a valid number:", err.Error())
 			os.Exit(1)
@@ -121,12 +245,56 @@ func parseFlags() cliArgs {
 			numberToAdd = int64(i)
 		}
 	}
+	if command == "cas" {
+		if i, err := strconv.Atoi(flag.Arg(3)); err != nil {
+			fmt.Println("new value does not seem to be a valid number:", err.Error())
+			os.Exit(1)
+		} else {
+			newNumber = int64(i)
+		}
+	}
 
 	return cliArgs{
-		command: command,
-		key:     key,
-		value:   numberToAdd,
+		pluginName:  *pluginName,
+		storageName: *storageName,
+		pluginDirs:  *pluginDirs,
+		timeout:     *timeout,
+		logLevel:    level,
+		config:      config.config,
+		policy:      policy,
+		command:     command,
+		key:         key,
+		value:       numberToAdd,
+		newValue:    newNumber,
+	}
+}
+
+// rangePolicy returns the policy for the --min and --max flags, or nil when
+// neither limit is given.
+func rangePolicy(minValue, maxValue string) (*sdk.RangePolicy, error) {
+	if len(minValue) == 0 && len(maxValue) == 0 {
+		return nil, nil
+	}
+
+	policy := &sdk.RangePolicy{Min: math.MinInt64, Max: math.MaxInt64}
+	if len(minValue) > 0 {
+		i, err := strconv.ParseInt(minValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("--min does not seem to be a valid number: %w", err)
+		}
+		policy.Min = i
+	}
+	if len(maxValue) > 0 {
+		i, err := strconv.ParseInt(maxValue, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("--max does not seem to be a valid number: %w", err)
+		}
+		policy.Max = i
+	}
+	if policy.Min > policy.Max {
+		return nil, fmt.Errorf("--min must not be larger than --max")
 	}
+	return policy, nil
 }
 
 // A HashiCorp Logger, configured to discard all logs.
@@ -0,0 +1,28 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile blocks until it holds a lock on the file, which is exclusive when
+// writing, otherwise shared with the other readers. The lock is held by the
+// open file, so is also respected by other processes, and other goroutines
+// opening the file themselves.
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	return syscall.Flock(int(f.Fd()), how)
+}
+
+// unlockFile releases the lock taken by lockFile.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,30 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build windows
+
+package main
+
+import (
+	"math"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile blocks until it holds a lock on the file, which is exclusive when
+// writing, otherwise shared with the other readers. The lock is held by the
+// open file, so is also respected by other processes, and other goroutines
+// opening the file themselves.
+func lockFile(f *os.File, exclusive bool) error {
+	var flags uint32
+	if exclusive {
+		flags = windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	return windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, math.MaxUint32, math.MaxUint32, &windows.Overlapped{})
+}
+
+// unlockFile releases the lock taken by lockFile.
+func unlockFile(f *os.File) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, math.MaxUint32, math.MaxUint32, &windows.Overlapped{})
+}
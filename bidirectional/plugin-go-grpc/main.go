@@ -5,21 +5,110 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"time"
 
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 
 	"github.com/mrcook/go-plugin-examples/bidirectional/sdk"
+	"github.com/mrcook/go-plugin-examples/storage"
 )
 
-// The KV store filename prefix for this plugin.
+// The default KV store filename prefix for this plugin, which the host
+// application can change with the "filename_prefix" config value. The files
+// are kept in the data directory given by the storage.DataDirEnv variable.
 const filenamePrefix = "kv_store_"
 
 // CounterPlugin is our custom plugin: it's a real implementation of the
 // CounterStore plugin type that updates and reads the number value stored
 // in the local file.
-type CounterPlugin struct{}
+//
+// The file paths are given by a storage.FileStore, so keys can not name a file
+// outside the data directory. The files are updated in place, rather than
+// replaced, as the lock held on a file during an update would not apply to
+// the file replacing it.
+type CounterPlugin struct {
+	logger  hclog.Logger
+	metrics sdk.MetricsEmitter
+	policy  sdk.Policy
+	store   *storage.FileStore
+}
+
+// newCounterPlugin returns a CounterPlugin that discards its logs and
+// metrics, until the host application provides its services.
+func newCounterPlugin() (*CounterPlugin, error) {
+	store, err := storage.NewFileStore(storage.DataDir(), filenamePrefix)
+	if err != nil {
+		return nil, err
+	}
+	return &CounterPlugin{
+		logger: hclog.NewNullLogger(),
+		store:  store,
+	}, nil
+}
+
+// SetHostServices is called by the sdk when the plugin is dispensed, so its
+// messages and metrics are recorded by the host application.
+func (k *CounterPlugin) SetHostServices(services *sdk.HostServices) {
+	k.logger = services.Logger
+	k.metrics = services.Metrics
+	k.policy = services.Policy
+	if services.Config == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	prefix, found, err := services.Config.Lookup(ctx, "filename_prefix")
+	if err != nil {
+		k.logger.Warn("looking up the filename prefix", "error", err)
+	} else if found {
+		store, err := storage.NewFileStore(k.store.Dir(), prefix)
+		if err != nil {
+			k.logger.Warn("using the filename prefix", "error", err)
+		} else {
+			k.store = store
+			k.logger.Debug("using host services", "filename_prefix", prefix)
+		}
+	}
+}
+
+// emit records a metric with the host application, if it provides metrics.
+func (k *CounterPlugin) emit(ctx context.Context, name string, value float64, key string) {
+	if k.metrics == nil {
+		return
+	}
+	if err := k.metrics.Emit(ctx, name, value, map[string]string{"key": key}); err != nil {
+		k.logger.Warn("emitting metric", "name", name, "error", err)
+	}
+}
+
+// validate returns an error when the host application's policy does not
+// allow the value to be stored for the key.
+func (k *CounterPlugin) validate(ctx context.Context, key string, value int64) error {
+	if k.policy == nil {
+		return nil
+	}
+	err := k.policy.Validate(ctx, key, value)
+	if err != nil {
+		k.logger.Warn("value rejected by the host policy", "key", key, "value", value, "error", err)
+	}
+	return err
+}
+
+// clamp returns the value limited by the host application's policy.
+func (k *CounterPlugin) clamp(ctx context.Context, key string, value int64) (int64, error) {
+	if k.policy == nil {
+		return value, nil
+	}
+	return k.policy.Clamp(ctx, key, value)
+}
 
 // storeData presents the JSON data stored in the local file.
 type storeData struct {
@@ -30,29 +119,164 @@ type storeData struct {
 // Before writing the data an RPC request is made to the host application
 // using the sdk.AddHelper.
 func (k *CounterPlugin) Put(key string, value int64, adder sdk.AddHelper) error {
-	v, _ := k.Get(key)
+	return k.PutContext(context.Background(), key, value, sdk.NewContextAddHelper(adder))
+}
+
+// Get reads the file for matching the key and returns the value stored therein.
+func (k *CounterPlugin) Get(key string) (int64, error) {
+	return k.GetContext(context.Background(), key)
+}
+
+// Increment adds the delta to the number stored in the file matching the key.
+func (k *CounterPlugin) Increment(key string, delta int64) (int64, error) {
+	return k.IncrementContext(context.Background(), key, delta)
+}
+
+// CompareAndSwap stores the new number in the file matching the key, when it
+// contains the old number.
+func (k *CounterPlugin) CompareAndSwap(key string, old, new int64) (bool, error) {
+	return k.CompareAndSwapContext(context.Background(), key, old, new)
+}
 
-	// Request the host application to add the two numbers. This feels like a
-	// normal method call but is in fact over an RPC connection.
-	r, err := adder.Sum(v, value)
+// PutContext is the context-aware variant of Put, and is the one called by
+// the sdk. The context is passed on to the host application when requesting
+// the sum, so the host's deadline also applies to that call.
+func (k *CounterPlugin) PutContext(ctx context.Context, key string, value int64, adder sdk.ContextAddHelper) error {
+	r, err := k.update(ctx, key, func(v int64) (int64, bool, error) {
+		// Request the host application to add the two numbers. This feels
+		// like a normal method call but is in fact over an RPC connection.
+		r, err := adder.SumContext(ctx, v, value)
+		if err != nil {
+			k.logger.Error("summing the counter", "key", key, "error", err)
+			return 0, false, err
+		}
+
+		// The host application decides whether the sum may be stored.
+		if err := k.validate(ctx, key, r); err != nil {
+			return 0, false, err
+		}
+		return r, true, nil
+	})
 	if err != nil {
 		return err
 	}
 
-	buf, err := json.Marshal(&storeData{r})
+	k.logger.Info("counter updated", "key", key, "added", value, "value", r)
+	k.emit(ctx, "counter.put", float64(value), key)
+	return nil
+}
+
+// GetContext is the context-aware variant of Get.
+func (k *CounterPlugin) GetContext(ctx context.Context, key string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	path, err := k.store.Path(key)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// A shared lock, so the file is not read while being written.
+	if err := lockFile(f, false); err != nil {
+		return 0, err
 	}
+	defer unlockFile(f)
 
-	return os.WriteFile(filenamePrefix+key, buf, 0644)
+	return readValue(f)
 }
 
-// Get reads the file for matching the key and returns the value stored therein.
-func (k *CounterPlugin) Get(key string) (int64, error) {
-	fileContents, err := os.ReadFile(filenamePrefix + key)
+// IncrementContext is the context-aware variant of Increment.
+func (k *CounterPlugin) IncrementContext(ctx context.Context, key string, delta int64) (int64, error) {
+	r, err := k.update(ctx, key, func(v int64) (int64, bool, error) {
+		// The counter saturates at the limits set by the host application.
+		r, err := k.clamp(ctx, key, v+delta)
+		return r, err == nil, err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	k.logger.Info("counter incremented", "key", key, "delta", delta, "value", r)
+	k.emit(ctx, "counter.increment", float64(delta), key)
+	return r, nil
+}
+
+// CompareAndSwapContext is the context-aware variant of CompareAndSwap.
+func (k *CounterPlugin) CompareAndSwapContext(ctx context.Context, key string, old, new int64) (bool, error) {
+	swapped := false
+	_, err := k.update(ctx, key, func(v int64) (int64, bool, error) {
+		if v != old {
+			return 0, false, nil
+		}
+		if err := k.validate(ctx, key, new); err != nil {
+			return 0, false, err
+		}
+		swapped = true
+		return new, true, nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	k.logger.Info("counter compared", "key", key, "old", old, "new", new, "swapped", swapped)
+	return swapped, nil
+}
+
+// update calls fn with the number stored in the file matching the key, then
+// writes the number it returns, when asked to. An exclusive lock is held on
+// the file for the whole update, so concurrent updates, whether made by this
+// or another plugin process, are applied one after the other.
+//
+// The number in the file when the update finishes is returned.
+func (k *CounterPlugin) update(ctx context.Context, key string, fn func(v int64) (int64, bool, error)) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	path, err := k.store.Path(key)
 	if err != nil {
 		return 0, err
 	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if err := lockFile(f, true); err != nil {
+		return 0, err
+	}
+	defer unlockFile(f)
+
+	v, err := readValue(f)
+	if err != nil {
+		return 0, err
+	}
+	r, write, err := fn(v)
+	if err != nil || !write {
+		return v, err
+	}
+
+	if err := writeValue(f, r); err != nil {
+		k.logger.Error("writing the counter", "key", key, "error", err)
+		return 0, err
+	}
+	return r, nil
+}
+
+// readValue returns the number stored in the file, with an empty file, such
+// as one just created, storing 0.
+func readValue(f *os.File) (int64, error) {
+	fileContents, err := io.ReadAll(f)
+	if err != nil || len(fileContents) == 0 {
+		return 0, err
+	}
 
 	data := &storeData{}
 	err = json.Unmarshal(fileContents, data)
@@ -63,13 +287,33 @@ func (k *CounterPlugin) Get(key string) (int64, error) {
 	return data.Value, nil
 }
 
+// writeValue replaces the contents of the file with the number.
+func writeValue(f *os.File, value int64) error {
+	buf, err := json.Marshal(&storeData{value})
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(buf, 0)
+	return err
+}
+
 // go-plugin's are normal Go applications so require a main entry point.
 // Once the host application has loaded (dispensed) the plugin, go-plugin will
 // start the plugin, and manage its full lifecycle.
 func main() {
+	counter, err := newCounterPlugin()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	// Assign our plugin as the required plugin type.
 	plugins := plugin.PluginSet{
-		sdk.CounterPluginName: &sdk.CounterPlugin{Impl: &CounterPlugin{}},
+		sdk.CounterPluginName: &sdk.CounterPlugin{Impl: counter},
 	}
 
 	// start listening for incoming gRPC requests.
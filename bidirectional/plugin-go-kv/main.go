@@ -0,0 +1,202 @@
+// A CounterStore plugin storing its counters in a KVStore plugin, which is
+// run by the host application and offered to this plugin as a host service.
+//
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+
+	"github.com/mrcook/go-plugin-examples/bidirectional/sdk"
+	kvsdk "github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// The counters are stored in the KVStore using keys with this prefix.
+const keyPrefix = "counter_"
+
+var errNoStorage = errors.New("the host application offers no storage for the counters")
+
+// CounterPlugin is a CounterStore that neither knows nor cares which KVStore
+// plugin the host application runs for its storage.
+//
+// The KVStore has no atomic updates, so the counters are updated one after
+// the other by this plugin, but not across several host processes.
+type CounterPlugin struct {
+	logger  hclog.Logger
+	policy  sdk.Policy
+	storage kvsdk.ContextKVStore
+
+	mu sync.Mutex // held while updating a counter
+}
+
+// storeData presents the JSON data stored as the value of each key.
+type storeData struct {
+	Value int64 `json:"value"`
+}
+
+// SetHostServices is called by the sdk when the plugin is dispensed, giving
+// the plugin the storage offered by the host application.
+func (k *CounterPlugin) SetHostServices(services *sdk.HostServices) {
+	k.logger = services.Logger
+	k.policy = services.Policy
+	if storage, ok := services.Storage.(kvsdk.ContextKVStore); ok {
+		k.storage = storage
+	}
+}
+
+// Put adds the given number to that stored for the key, with the sum being
+// done by the host application using the sdk.AddHelper.
+func (k *CounterPlugin) Put(key string, value int64, adder sdk.AddHelper) error {
+	return k.PutContext(context.Background(), key, value, sdk.NewContextAddHelper(adder))
+}
+
+// Get returns the number stored for the key.
+func (k *CounterPlugin) Get(key string) (int64, error) {
+	return k.GetContext(context.Background(), key)
+}
+
+// Increment adds the delta to the number stored for the key.
+func (k *CounterPlugin) Increment(key string, delta int64) (int64, error) {
+	return k.IncrementContext(context.Background(), key, delta)
+}
+
+// CompareAndSwap stores the new number for the key, when the old number is
+// stored.
+func (k *CounterPlugin) CompareAndSwap(key string, old, new int64) (bool, error) {
+	return k.CompareAndSwapContext(context.Background(), key, old, new)
+}
+
+// PutContext is the context-aware variant of Put. The context is passed on
+// to the host application for the sum, and to the storage.
+func (k *CounterPlugin) PutContext(ctx context.Context, key string, value int64, adder sdk.ContextAddHelper) error {
+	_, err := k.update(ctx, key, func(v int64) (int64, bool, error) {
+		r, err := adder.SumContext(ctx, v, value)
+		if err != nil {
+			return 0, false, err
+		}
+		if err := k.validate(ctx, key, r); err != nil {
+			return 0, false, err
+		}
+		return r, true, nil
+	})
+	return err
+}
+
+// GetContext is the context-aware variant of Get.
+func (k *CounterPlugin) GetContext(ctx context.Context, key string) (int64, error) {
+	if k.storage == nil {
+		return 0, errNoStorage
+	}
+
+	value, err := k.storage.GetContext(ctx, keyPrefix+key)
+	if err != nil {
+		return 0, err
+	}
+	return decodeValue(value)
+}
+
+// IncrementContext is the context-aware variant of Increment.
+func (k *CounterPlugin) IncrementContext(ctx context.Context, key string, delta int64) (int64, error) {
+	return k.update(ctx, key, func(v int64) (int64, bool, error) {
+		if k.policy == nil {
+			return v + delta, true, nil
+		}
+		r, err := k.policy.Clamp(ctx, key, v+delta)
+		return r, err == nil, err
+	})
+}
+
+// CompareAndSwapContext is the context-aware variant of CompareAndSwap.
+func (k *CounterPlugin) CompareAndSwapContext(ctx context.Context, key string, old, new int64) (bool, error) {
+	swapped := false
+	_, err := k.update(ctx, key, func(v int64) (int64, bool, error) {
+		if v != old {
+			return 0, false, nil
+		}
+		if err := k.validate(ctx, key, new); err != nil {
+			return 0, false, err
+		}
+		swapped = true
+		return new, true, nil
+	})
+	return swapped, err
+}
+
+// update calls fn with the number stored for the key, with a key not yet
+// stored having the number 0, then stores the number it returns, when asked
+// to. The number stored when the update finishes is returned.
+func (k *CounterPlugin) update(ctx context.Context, key string, fn func(v int64) (int64, bool, error)) (int64, error) {
+	if k.storage == nil {
+		return 0, errNoStorage
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	v, err := k.GetContext(ctx, key)
+	if err != nil && !errors.Is(err, kvsdk.ErrNotFound) {
+		return 0, err
+	}
+	r, write, err := fn(v)
+	if err != nil || !write {
+		return v, err
+	}
+
+	buf, err := json.Marshal(&storeData{r})
+	if err != nil {
+		return 0, err
+	}
+	if err := k.storage.PutContext(ctx, keyPrefix+key, buf); err != nil {
+		return 0, err
+	}
+	k.logger.Info("counter updated", "key", key, "value", r)
+	return r, nil
+}
+
+// validate returns an error when the host application's policy does not
+// allow the value to be stored for the key.
+func (k *CounterPlugin) validate(ctx context.Context, key string, value int64) error {
+	if k.policy == nil {
+		return nil
+	}
+	return k.policy.Validate(ctx, key, value)
+}
+
+// decodeValue returns the number of the stored value. Storage plugins may
+// add their own note after the value, so only the first JSON value is read.
+func decodeValue(value []byte) (int64, error) {
+	data := &storeData{}
+	if err := json.NewDecoder(bytes.NewReader(value)).Decode(data); err != nil {
+		return 0, fmt.Errorf("the stored value is not a counter: %q", value)
+	}
+	return data.Value, nil
+}
+
+// go-plugin's are normal Go applications so require a main entry point.
+// Once the host application has loaded (dispensed) the plugin, go-plugin will
+// start the plugin, and manage its full lifecycle.
+func main() {
+	// Assign our plugin as the required plugin type.
+	plugins := plugin.PluginSet{
+		sdk.CounterPluginName: &sdk.CounterPlugin{Impl: &CounterPlugin{logger: hclog.NewNullLogger()}},
+	}
+
+	// start listening for incoming gRPC requests.
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: sdk.HandshakeConfig,
+		Plugins:         plugins,
+
+		// A non-nil value here enables gRPC serving for this plugin.
+		GRPCServer: plugin.DefaultGRPCServer,
+	})
+}
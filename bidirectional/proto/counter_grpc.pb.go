@@ -0,0 +1,747 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: proto/counter.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Counter_Get_FullMethodName             = "/counter.Counter/Get"
+	Counter_Put_FullMethodName             = "/counter.Counter/Put"
+	Counter_Increment_FullMethodName       = "/counter.Counter/Increment"
+	Counter_CompareAndSwap_FullMethodName  = "/counter.Counter/CompareAndSwap"
+	Counter_SetHostServices_FullMethodName = "/counter.Counter/SetHostServices"
+)
+
+// CounterClient is the client API for Counter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CounterClient interface {
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*Empty, error)
+	Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error)
+	CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapResponse, error)
+	SetHostServices(ctx context.Context, in *HostServicesRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type counterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCounterClient(cc grpc.ClientConnInterface) CounterClient {
+	return &counterClient{cc}
+}
+
+func (c *counterClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, Counter_Get_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Counter_Put_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterClient) Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error) {
+	out := new(IncrementResponse)
+	err := c.cc.Invoke(ctx, Counter_Increment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterClient) CompareAndSwap(ctx context.Context, in *CompareAndSwapRequest, opts ...grpc.CallOption) (*CompareAndSwapResponse, error) {
+	out := new(CompareAndSwapResponse)
+	err := c.cc.Invoke(ctx, Counter_CompareAndSwap_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterClient) SetHostServices(ctx context.Context, in *HostServicesRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, Counter_SetHostServices_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CounterServer is the server API for Counter service.
+// All implementations must embed UnimplementedCounterServer
+// for forward compatibility
+type CounterServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Put(context.Context, *PutRequest) (*Empty, error)
+	Increment(context.Context, *IncrementRequest) (*IncrementResponse, error)
+	CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapResponse, error)
+	SetHostServices(context.Context, *HostServicesRequest) (*Empty, error)
+	mustEmbedUnimplementedCounterServer()
+}
+
+// UnimplementedCounterServer must be embedded to have forward compatible implementations.
+type UnimplementedCounterServer struct {
+}
+
+func (UnimplementedCounterServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedCounterServer) Put(context.Context, *PutRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+}
+func (UnimplementedCounterServer) Increment(context.Context, *IncrementRequest) (*IncrementResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Increment not implemented")
+}
+func (UnimplementedCounterServer) CompareAndSwap(context.Context, *CompareAndSwapRequest) (*CompareAndSwapResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompareAndSwap not implemented")
+}
+func (UnimplementedCounterServer) SetHostServices(context.Context, *HostServicesRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetHostServices not implemented")
+}
+func (UnimplementedCounterServer) mustEmbedUnimplementedCounterServer() {}
+
+// UnsafeCounterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CounterServer will
+// result in compilation errors.
+type UnsafeCounterServer interface {
+	mustEmbedUnimplementedCounterServer()
+}
+
+func RegisterCounterServer(s grpc.ServiceRegistrar, srv CounterServer) {
+	s.RegisterService(&Counter_ServiceDesc, srv)
+}
+
+func _Counter_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counter_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counter_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counter_Put_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).Put(ctx, req.(*PutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counter_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counter_Increment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).Increment(ctx, req.(*IncrementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counter_CompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompareAndSwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).CompareAndSwap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counter_CompareAndSwap_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).CompareAndSwap(ctx, req.(*CompareAndSwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Counter_SetHostServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HostServicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServer).SetHostServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Counter_SetHostServices_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServer).SetHostServices(ctx, req.(*HostServicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Counter_ServiceDesc is the grpc.ServiceDesc for Counter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Counter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "counter.Counter",
+	HandlerType: (*CounterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _Counter_Get_Handler,
+		},
+		{
+			MethodName: "Put",
+			Handler:    _Counter_Put_Handler,
+		},
+		{
+			MethodName: "Increment",
+			Handler:    _Counter_Increment_Handler,
+		},
+		{
+			MethodName: "CompareAndSwap",
+			Handler:    _Counter_CompareAndSwap_Handler,
+		},
+		{
+			MethodName: "SetHostServices",
+			Handler:    _Counter_SetHostServices_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/counter.proto",
+}
+
+const (
+	AddHelper_Sum_FullMethodName = "/counter.AddHelper/Sum"
+)
+
+// AddHelperClient is the client API for AddHelper service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AddHelperClient interface {
+	Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumResponse, error)
+}
+
+type addHelperClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAddHelperClient(cc grpc.ClientConnInterface) AddHelperClient {
+	return &addHelperClient{cc}
+}
+
+func (c *addHelperClient) Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumResponse, error) {
+	out := new(SumResponse)
+	err := c.cc.Invoke(ctx, AddHelper_Sum_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddHelperServer is the server API for AddHelper service.
+// All implementations must embed UnimplementedAddHelperServer
+// for forward compatibility
+type AddHelperServer interface {
+	Sum(context.Context, *SumRequest) (*SumResponse, error)
+	mustEmbedUnimplementedAddHelperServer()
+}
+
+// UnimplementedAddHelperServer must be embedded to have forward compatible implementations.
+type UnimplementedAddHelperServer struct {
+}
+
+func (UnimplementedAddHelperServer) Sum(context.Context, *SumRequest) (*SumResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Sum not implemented")
+}
+func (UnimplementedAddHelperServer) mustEmbedUnimplementedAddHelperServer() {}
+
+// UnsafeAddHelperServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AddHelperServer will
+// result in compilation errors.
+type UnsafeAddHelperServer interface {
+	mustEmbedUnimplementedAddHelperServer()
+}
+
+func RegisterAddHelperServer(s grpc.ServiceRegistrar, srv AddHelperServer) {
+	s.RegisterService(&AddHelper_ServiceDesc, srv)
+}
+
+func _AddHelper_Sum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SumRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AddHelperServer).Sum(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AddHelper_Sum_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AddHelperServer).Sum(ctx, req.(*SumRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AddHelper_ServiceDesc is the grpc.ServiceDesc for AddHelper service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AddHelper_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "counter.AddHelper",
+	HandlerType: (*AddHelperServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Sum",
+			Handler:    _AddHelper_Sum_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/counter.proto",
+}
+
+const (
+	HostLogger_Log_FullMethodName = "/counter.HostLogger/Log"
+)
+
+// HostLoggerClient is the client API for HostLogger service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HostLoggerClient interface {
+	Log(ctx context.Context, in *LogRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type hostLoggerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHostLoggerClient(cc grpc.ClientConnInterface) HostLoggerClient {
+	return &hostLoggerClient{cc}
+}
+
+func (c *hostLoggerClient) Log(ctx context.Context, in *LogRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, HostLogger_Log_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HostLoggerServer is the server API for HostLogger service.
+// All implementations must embed UnimplementedHostLoggerServer
+// for forward compatibility
+type HostLoggerServer interface {
+	Log(context.Context, *LogRequest) (*Empty, error)
+	mustEmbedUnimplementedHostLoggerServer()
+}
+
+// UnimplementedHostLoggerServer must be embedded to have forward compatible implementations.
+type UnimplementedHostLoggerServer struct {
+}
+
+func (UnimplementedHostLoggerServer) Log(context.Context, *LogRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Log not implemented")
+}
+func (UnimplementedHostLoggerServer) mustEmbedUnimplementedHostLoggerServer() {}
+
+// UnsafeHostLoggerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HostLoggerServer will
+// result in compilation errors.
+type UnsafeHostLoggerServer interface {
+	mustEmbedUnimplementedHostLoggerServer()
+}
+
+func RegisterHostLoggerServer(s grpc.ServiceRegistrar, srv HostLoggerServer) {
+	s.RegisterService(&HostLogger_ServiceDesc, srv)
+}
+
+func _HostLogger_Log_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HostLoggerServer).Log(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HostLogger_Log_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HostLoggerServer).Log(ctx, req.(*LogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// HostLogger_ServiceDesc is the grpc.ServiceDesc for HostLogger service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HostLogger_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "counter.HostLogger",
+	HandlerType: (*HostLoggerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Log",
+			Handler:    _HostLogger_Log_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/counter.proto",
+}
+
+const (
+	HostConfig_Lookup_FullMethodName = "/counter.HostConfig/Lookup"
+)
+
+// HostConfigClient is the client API for HostConfig service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HostConfigClient interface {
+	Lookup(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error)
+}
+
+type hostConfigClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHostConfigClient(cc grpc.ClientConnInterface) HostConfigClient {
+	return &hostConfigClient{cc}
+}
+
+func (c *hostConfigClient) Lookup(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error) {
+	out := new(ConfigResponse)
+	err := c.cc.Invoke(ctx, HostConfig_Lookup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HostConfigServer is the server API for HostConfig service.
+// All implementations must embed UnimplementedHostConfigServer
+// for forward compatibility
+type HostConfigServer interface {
+	Lookup(context.Context, *ConfigRequest) (*ConfigResponse, error)
+	mustEmbedUnimplementedHostConfigServer()
+}
+
+// UnimplementedHostConfigServer must be embedded to have forward compatible implementations.
+type UnimplementedHostConfigServer struct {
+}
+
+func (UnimplementedHostConfigServer) Lookup(context.Context, *ConfigRequest) (*ConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Lookup not implemented")
+}
+func (UnimplementedHostConfigServer) mustEmbedUnimplementedHostConfigServer() {}
+
+// UnsafeHostConfigServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HostConfigServer will
+// result in compilation errors.
+type UnsafeHostConfigServer interface {
+	mustEmbedUnimplementedHostConfigServer()
+}
+
+func RegisterHostConfigServer(s grpc.ServiceRegistrar, srv HostConfigServer) {
+	s.RegisterService(&HostConfig_ServiceDesc, srv)
+}
+
+func _HostConfig_Lookup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HostConfigServer).Lookup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HostConfig_Lookup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HostConfigServer).Lookup(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// HostConfig_ServiceDesc is the grpc.ServiceDesc for HostConfig service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HostConfig_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "counter.HostConfig",
+	HandlerType: (*HostConfigServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Lookup",
+			Handler:    _HostConfig_Lookup_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/counter.proto",
+}
+
+const (
+	HostMetrics_Emit_FullMethodName = "/counter.HostMetrics/Emit"
+)
+
+// HostMetricsClient is the client API for HostMetrics service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HostMetricsClient interface {
+	Emit(ctx context.Context, in *MetricRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type hostMetricsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHostMetricsClient(cc grpc.ClientConnInterface) HostMetricsClient {
+	return &hostMetricsClient{cc}
+}
+
+func (c *hostMetricsClient) Emit(ctx context.Context, in *MetricRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, HostMetrics_Emit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HostMetricsServer is the server API for HostMetrics service.
+// All implementations must embed UnimplementedHostMetricsServer
+// for forward compatibility
+type HostMetricsServer interface {
+	Emit(context.Context, *MetricRequest) (*Empty, error)
+	mustEmbedUnimplementedHostMetricsServer()
+}
+
+// UnimplementedHostMetricsServer must be embedded to have forward compatible implementations.
+type UnimplementedHostMetricsServer struct {
+}
+
+func (UnimplementedHostMetricsServer) Emit(context.Context, *MetricRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Emit not implemented")
+}
+func (UnimplementedHostMetricsServer) mustEmbedUnimplementedHostMetricsServer() {}
+
+// UnsafeHostMetricsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HostMetricsServer will
+// result in compilation errors.
+type UnsafeHostMetricsServer interface {
+	mustEmbedUnimplementedHostMetricsServer()
+}
+
+func RegisterHostMetricsServer(s grpc.ServiceRegistrar, srv HostMetricsServer) {
+	s.RegisterService(&HostMetrics_ServiceDesc, srv)
+}
+
+func _HostMetrics_Emit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MetricRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HostMetricsServer).Emit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HostMetrics_Emit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HostMetricsServer).Emit(ctx, req.(*MetricRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// HostMetrics_ServiceDesc is the grpc.ServiceDesc for HostMetrics service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HostMetrics_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "counter.HostMetrics",
+	HandlerType: (*HostMetricsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Emit",
+			Handler:    _HostMetrics_Emit_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/counter.proto",
+}
+
+const (
+	HostPolicy_Validate_FullMethodName = "/counter.HostPolicy/Validate"
+	HostPolicy_Clamp_FullMethodName    = "/counter.HostPolicy/Clamp"
+)
+
+// HostPolicyClient is the client API for HostPolicy service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type HostPolicyClient interface {
+	Validate(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*Empty, error)
+	Clamp(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*ClampResponse, error)
+}
+
+type hostPolicyClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHostPolicyClient(cc grpc.ClientConnInterface) HostPolicyClient {
+	return &hostPolicyClient{cc}
+}
+
+func (c *hostPolicyClient) Validate(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, HostPolicy_Validate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hostPolicyClient) Clamp(ctx context.Context, in *PolicyRequest, opts ...grpc.CallOption) (*ClampResponse, error) {
+	out := new(ClampResponse)
+	err := c.cc.Invoke(ctx, HostPolicy_Clamp_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HostPolicyServer is the server API for HostPolicy service.
+// All implementations must embed UnimplementedHostPolicyServer
+// for forward compatibility
+type HostPolicyServer interface {
+	Validate(context.Context, *PolicyRequest) (*Empty, error)
+	Clamp(context.Context, *PolicyRequest) (*ClampResponse, error)
+	mustEmbedUnimplementedHostPolicyServer()
+}
+
+// UnimplementedHostPolicyServer must be embedded to have forward compatible implementations.
+type UnimplementedHostPolicyServer struct {
+}
+
+func (UnimplementedHostPolicyServer) Validate(context.Context, *PolicyRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+func (UnimplementedHostPolicyServer) Clamp(context.Context, *PolicyRequest) (*ClampResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Clamp not implemented")
+}
+func (UnimplementedHostPolicyServer) mustEmbedUnimplementedHostPolicyServer() {}
+
+// UnsafeHostPolicyServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to HostPolicyServer will
+// result in compilation errors.
+type UnsafeHostPolicyServer interface {
+	mustEmbedUnimplementedHostPolicyServer()
+}
+
+func RegisterHostPolicyServer(s grpc.ServiceRegistrar, srv HostPolicyServer) {
+	s.RegisterService(&HostPolicy_ServiceDesc, srv)
+}
+
+func _HostPolicy_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HostPolicyServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HostPolicy_Validate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HostPolicyServer).Validate(ctx, req.(*PolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HostPolicy_Clamp_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PolicyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HostPolicyServer).Clamp(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HostPolicy_Clamp_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HostPolicyServer).Clamp(ctx, req.(*PolicyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// HostPolicy_ServiceDesc is the grpc.ServiceDesc for HostPolicy service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var HostPolicy_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "counter.HostPolicy",
+	HandlerType: (*HostPolicyServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Validate",
+			Handler:    _HostPolicy_Validate_Handler,
+		},
+		{
+			MethodName: "Clamp",
+			Handler:    _HostPolicy_Clamp_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "proto/counter.proto",
+}
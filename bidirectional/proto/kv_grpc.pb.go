@@ -1,239 +0,0 @@
-// Copyright (c) HashiCorp, Inc.
-// SPDX-License-Identifier: MPL-2.0
-
-// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
-// versions:
-// - protoc-gen-go-grpc v1.3.0
-// - protoc             v3.21.12
-// source: proto/kv.proto
-
-package proto
-
-import (
-	context "context"
-	grpc "google.golang.org/grpc"
-	codes "google.golang.org/grpc/codes"
-	status "google.golang.org/grpc/status"
-)
-
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-// Requires gRPC-Go v1.32.0 or later.
-const _ = grpc.SupportPackageIsVersion7
-
-const (
-	Counter_Get_FullMethodName = "/proto.Counter/Get"
-	Counter_Put_FullMethodName = "/proto.Counter/Put"
-)
-
-// CounterClient is the client API for Counter service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
-type CounterClient interface {
-	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
-	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*Empty, error)
-}
-
-type counterClient struct {
-	cc grpc.ClientConnInterface
-}
-
-func NewCounterClient(cc grpc.ClientConnInterface) CounterClient {
-	return &counterClient{cc}
-}
-
-func (c *counterClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
-	out := new(GetResponse)
-	err := c.cc.Invoke(ctx, Counter_Get_FullMethodName, in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-func (c *counterClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*Empty, error) {
-	out := new(Empty)
-	err := c.cc.Invoke(ctx, Counter_Put_FullMethodName, in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-// CounterServer is the server API for Counter service.
-// All implementations must embed UnimplementedCounterServer
-// for forward compatibility
-type CounterServer interface {
-	Get(context.Context, *GetRequest) (*GetResponse, error)
-	Put(context.Context, *PutRequest) (*Empty, error)
-	mustEmbedUnimplementedCounterServer()
-}
-
-// UnimplementedCounterServer must be embedded to have forward compatible implementations.
-type UnimplementedCounterServer struct {
-}
-
-func (UnimplementedCounterServer) Get(context.Context, *GetRequest) (*GetResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
-}
-func (UnimplementedCounterServer) Put(context.Context, *PutRequest) (*Empty, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
-}
-func (UnimplementedCounterServer) mustEmbedUnimplementedCounterServer() {}
-
-// UnsafeCounterServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to CounterServer will
-// result in compilation errors.
-type UnsafeCounterServer interface {
-	mustEmbedUnimplementedCounterServer()
-}
-
-func RegisterCounterServer(s grpc.ServiceRegistrar, srv CounterServer) {
-	s.RegisterService(&Counter_ServiceDesc, srv)
-}
-
-func _Counter_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(GetRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(CounterServer).Get(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: Counter_Get_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CounterServer).Get(ctx, req.(*GetRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _Counter_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(PutRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(CounterServer).Put(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: Counter_Put_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(CounterServer).Put(ctx, req.(*PutRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-// Counter_ServiceDesc is the grpc.ServiceDesc for Counter service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var Counter_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "proto.Counter",
-	HandlerType: (*CounterServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Get",
-			Handler:    _Counter_Get_Handler,
-		},
-		{
-			MethodName: "Put",
-			Handler:    _Counter_Put_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "proto/kv.proto",
-}
-
-const (
-	AddHelper_Sum_FullMethodName = "/proto.AddHelper/Sum"
-)
-
-// AddHelperClient is the client API for AddHelper service.
-//
-// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
-type AddHelperClient interface {
-	Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumResponse, error)
-}
-
-type addHelperClient struct {
-	cc grpc.ClientConnInterface
-}
-
-func NewAddHelperClient(cc grpc.ClientConnInterface) AddHelperClient {
-	return &addHelperClient{cc}
-}
-
-func (c *addHelperClient) Sum(ctx context.Context, in *SumRequest, opts ...grpc.CallOption) (*SumResponse, error) {
-	out := new(SumResponse)
-	err := c.cc.Invoke(ctx, AddHelper_Sum_FullMethodName, in, out, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
-}
-
-// AddHelperServer is the server API for AddHelper service.
-// All implementations must embed UnimplementedAddHelperServer
-// for forward compatibility
-type AddHelperServer interface {
-	Sum(context.Context, *SumRequest) (*SumResponse, error)
-	mustEmbedUnimplementedAddHelperServer()
-}
-
-// UnimplementedAddHelperServer must be embedded to have forward compatible implementations.
-type UnimplementedAddHelperServer struct {
-}
-
-func (UnimplementedAddHelperServer) Sum(context.Context, *SumRequest) (*SumResponse, error) {
-	return nil, status.Errorf(codes.Unimplemented, "method Sum not implemented")
-}
-func (UnimplementedAddHelperServer) mustEmbedUnimplementedAddHelperServer() {}
-
-// UnsafeAddHelperServer may be embedded to opt out of forward compatibility for this service.
-// Use of this interface is not recommended, as added methods to AddHelperServer will
-// result in compilation errors.
-type UnsafeAddHelperServer interface {
-	mustEmbedUnimplementedAddHelperServer()
-}
-
-func RegisterAddHelperServer(s grpc.ServiceRegistrar, srv AddHelperServer) {
-	s.RegisterService(&AddHelper_ServiceDesc, srv)
-}
-
-func _AddHelper_Sum_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(SumRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(AddHelperServer).Sum(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: AddHelper_Sum_FullMethodName,
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(AddHelperServer).Sum(ctx, req.(*SumRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-// AddHelper_ServiceDesc is the grpc.ServiceDesc for AddHelper service.
-// It's only intended for direct use with grpc.RegisterService,
-// and not to be introspected or modified (even as a copy)
-var AddHelper_ServiceDesc = grpc.ServiceDesc{
-	ServiceName: "proto.AddHelper",
-	HandlerType: (*AddHelperServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Sum",
-			Handler:    _AddHelper_Sum_Handler,
-		},
-	},
-	Streams:  []grpc.StreamDesc{},
-	Metadata: "proto/kv.proto",
-}
@@ -0,0 +1,156 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hashicorp/go-plugin"
+
+	"github.com/mrcook/go-plugin-examples/bidirectional/sdk"
+	"github.com/mrcook/go-plugin-examples/discovery"
+	kvsdk "github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/repl"
+)
+
+// replSession holds the plugin used by the REPL, which is replaced by the
+// switch-plugin command.
+type replSession struct {
+	args         cliArgs
+	pluginName   string
+	pluginClient *plugin.Client
+	counter      sdk.ContextCounterStore
+	storage      kvsdk.KVStore // offered to every plugin, when not nil
+	repl         *repl.REPL
+}
+
+// runREPL reads commands until the user exits, making each request to the
+// same plugin process. The plugin in use when the REPL exits is stopped.
+func runREPL(pluginClient *plugin.Client, counter sdk.ContextCounterStore, storage kvsdk.KVStore, args cliArgs) error {
+	s := &replSession{
+		args:         args,
+		pluginName:   args.pluginName,
+		pluginClient: pluginClient,
+		counter:      counter,
+		storage:      storage,
+	}
+	defer func() { s.pluginClient.Kill() }()
+
+	s.repl = repl.New(repl.Config{
+		Prompt:      "counter> ",
+		HistoryFile: repl.HistoryFile("counter"),
+		Commands: []repl.Command{
+			{Name: "get", Args: "<key>", Help: "print the number stored for the key", MinArgs: 1, Run: s.get},
+			{Name: "put", Args: "<key> <number>", Help: "add the number to that stored for the key", MinArgs: 2, Run: s.put},
+			{Name: "increment", Args: "<key> <delta>", Help: "atomically add the delta to the number stored for the key", MinArgs: 2, Run: s.increment},
+			{Name: "cas", Args: "<key> <old> <new>", Help: "store the new number for the key, only when the old number is stored", MinArgs: 3, Run: s.compareAndSwap},
+			{Name: "switch-plugin", Args: "<name>", Help: "switch to another discovered plugin", MinArgs: 1, Completions: s.pluginNames(), Run: s.switchPlugin},
+			{Name: "stats", Help: "print the plugin in use, the time taken by each command, and the plugin metrics", Run: s.stats},
+		},
+	})
+	return s.repl.Run()
+}
+
+func (s *replSession) get(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.args.timeout)
+	defer cancel()
+
+	value, err := s.counter.GetContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func (s *replSession) put(args []string) error {
+	value, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("value does not seem to be a valid number: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.args.timeout)
+	defer cancel()
+
+	// Provide our trusted helper for doing the summation work.
+	return s.counter.PutContext(ctx, args[0], value, &hostAddHelper{})
+}
+
+func (s *replSession) increment(args []string) error {
+	delta, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("delta does not seem to be a valid number: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.args.timeout)
+	defer cancel()
+
+	value, err := s.counter.IncrementContext(ctx, args[0], delta)
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func (s *replSession) compareAndSwap(args []string) error {
+	old, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("old value does not seem to be a valid number: %w", err)
+	}
+	new, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		return fmt.Errorf("new value does not seem to be a valid number: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.args.timeout)
+	defer cancel()
+
+	swapped, err := s.counter.CompareAndSwapContext(ctx, args[0], old, new)
+	if err != nil {
+		return err
+	}
+	fmt.Println(swapped)
+	return nil
+}
+
+// switchPlugin starts the named plugin, and only once it is running, stops
+// the plugin that was in use.
+func (s *replSession) switchPlugin(args []string) error {
+	pluginClient, counter, err := startPlugin(args[0], s.args, s.storage)
+	if err != nil {
+		return err
+	}
+	s.pluginClient.Kill()
+
+	s.pluginName = args[0]
+	s.pluginClient = pluginClient
+	s.counter = counter
+	fmt.Println("Switched to plugin", args[0])
+	return nil
+}
+
+func (s *replSession) stats(_ []string) error {
+	fmt.Printf("Plugin %s, using %s\n\n", s.pluginName, s.pluginClient.Protocol())
+	s.repl.WriteStats(os.Stdout)
+	fmt.Println()
+	metrics.write(os.Stdout)
+	return nil
+}
+
+// pluginNames returns the names of the discovered plugins, which are
+// completed by the switch-plugin command.
+func (s *replSession) pluginNames() []string {
+	registry, err := discovery.Discover(discovery.Config{
+		Dirs:    discovery.SplitDirs(s.args.pluginDirs),
+		Pattern: pluginPattern,
+	})
+	if err != nil {
+		return nil
+	}
+	return registry.Names()
+}
@@ -14,7 +14,10 @@ var HandshakeConfig = plugin.HandshakeConfig{
 	// In general it is better to omit ProtocolVersion and explicitly set
 	// VersionedPlugins in Client/Server configurations.
 	// For simplicity, ProtocolVersion is used in this example.
-	ProtocolVersion: 1,
+	//
+	// Version 2 renamed the proto package to counter, which changed the names
+	// of the gRPC services, so plugins built for version 1 can not be used.
+	ProtocolVersion: 2,
 
 	// Once set, these magic cookie values should NEVER be changed.
 	MagicCookieKey:   "BIDIRECTIONAL_PLUGIN", // a unique key for your application
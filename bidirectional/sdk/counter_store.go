@@ -23,6 +23,15 @@ import (
 type CounterStore interface {
 	Put(key string, value int64, a AddHelper) error
 	Get(key string) (int64, error)
+
+	// Increment atomically adds the delta to the number stored for the key,
+	// and returns the new number. A key not yet stored has the number 0.
+	Increment(key string, delta int64) (int64, error)
+
+	// CompareAndSwap atomically stores the new number for the key, only when
+	// the number stored is old, and reports whether it was stored. A key not
+	// yet stored has the number 0.
+	CompareAndSwap(key string, old, new int64) (bool, error)
 }
 
 // AddHelper is the interface being exposed for use with CounterStore plugins,
@@ -31,6 +40,45 @@ type AddHelper interface {
 	Sum(int64, int64) (int64, error)
 }
 
+// ContextCounterStore is the context-aware variant of the CounterStore
+// interface.
+//
+// The client dispensed to host applications implements this interface, so a
+// deadline or cancellation can be set on each request. Plugins may also
+// implement it, in which case the context of the incoming request is passed
+// to these methods instead of those of the CounterStore. Passing that context
+// on to the ContextAddHelper sends the deadline back to the host application.
+type ContextCounterStore interface {
+	PutContext(ctx context.Context, key string, value int64, a ContextAddHelper) error
+	GetContext(ctx context.Context, key string) (int64, error)
+	IncrementContext(ctx context.Context, key string, delta int64) (int64, error)
+	CompareAndSwapContext(ctx context.Context, key string, old, new int64) (bool, error)
+}
+
+// ContextAddHelper is the context-aware variant of the AddHelper interface.
+type ContextAddHelper interface {
+	SumContext(ctx context.Context, a, b int64) (int64, error)
+}
+
+// NewContextAddHelper wraps an AddHelper so that it can be used where a
+// ContextAddHelper is expected. The AddHelper is not called once the context
+// is done.
+func NewContextAddHelper(a AddHelper) ContextAddHelper {
+	return &contextAddHelper{helper: a}
+}
+
+// contextAddHelper is the ContextAddHelper returned by NewContextAddHelper.
+type contextAddHelper struct {
+	helper AddHelper
+}
+
+func (h *contextAddHelper) SumContext(ctx context.Context, a, b int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return h.helper.Sum(a, b)
+}
+
 // CounterPluginName is an important variable.
 // All CounterStore plugins MUST use the same value in their plugin.ServeConfig
 // when specifying the plugins (pluginMap).
@@ -52,6 +100,10 @@ type CounterPlugin struct {
 	// Concrete implementation, written in Go.
 	// This is only used for plugins that are written in Go.
 	Impl CounterStore
+
+	// HostServices are offered to the plugin when it is dispensed.
+	// This is only used by host applications.
+	HostServices *HostServices
 }
 
 // GRPCServer must return a gRPC server for this plugin type.
@@ -62,6 +114,18 @@ func (p *CounterPlugin) GRPCServer(broker *plugin.GRPCBroker, s *grpc.Server) er
 
 // GRPCClient must return an implementation of our interface that communicates
 // over a gRPC client.
-func (p *CounterPlugin) GRPCClient(_ context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
-	return &grpcCounterClient{client: proto.NewCounterClient(c), broker: broker}, nil
+func (p *CounterPlugin) GRPCClient(ctx context.Context, broker *plugin.GRPCBroker, c *grpc.ClientConn) (interface{}, error) {
+	client := proto.NewCounterClient(c)
+
+	// The plugin calls back to a single server for the AddHelpers of all the
+	// Put requests, and for the host services.
+	callbacks, err := startCallbackServer(ctx, broker, p.HostServices)
+	if err != nil {
+		return nil, err
+	}
+	if err := callbacks.connect(ctx, client); err != nil {
+		callbacks.close()
+		return nil, err
+	}
+	return &grpcCounterClient{client: client, callbacks: callbacks}, nil
 }
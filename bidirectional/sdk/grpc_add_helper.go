@@ -6,21 +6,34 @@ package sdk
 
 import (
 	"context"
+	"sync"
 
 	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/mrcook/go-plugin-examples/bidirectional/proto"
 )
 
 // grpcAddHelperClient is an implementation of AddHelper that talks over RPC.
+// It also implements ContextAddHelper, so plugins can pass on the deadline
+// they received from the host application.
+//
+// The callID identifies the AddHelper given to the Put call being handled,
+// as the host serves the helpers of all its calls on the same connection.
 type grpcAddHelperClient struct {
 	client proto.AddHelperClient
+	callID uint64
 }
 
 func (c *grpcAddHelperClient) Sum(a, b int64) (int64, error) {
+	return c.SumContext(context.Background(), a, b)
+}
+
+func (c *grpcAddHelperClient) SumContext(ctx context.Context, a, b int64) (int64, error) {
 	resp, err := c.client.Sum(
-		context.Background(),
-		&proto.SumRequest{A: a, B: b},
+		ctx,
+		&proto.SumRequest{A: a, B: b, CallId: c.callID},
 	)
 	if err != nil {
 		hclog.Default().Info("add.Sum", "client", "start", "err", err)
@@ -30,16 +43,58 @@ func (c *grpcAddHelperClient) Sum(a, b int64) (int64, error) {
 }
 
 // grpcAddHelperServer is the gRPC server that grpcAddHelperClient talks to.
+// It serves the AddHelpers of all the Put calls in progress.
 type grpcAddHelperServer struct {
 	proto.UnimplementedAddHelperServer // enable forward-compatibility
 
-	Impl AddHelper
+	helpers *addHelpers
 }
 
-func (s *grpcAddHelperServer) Sum(_ context.Context, req *proto.SumRequest) (*proto.SumResponse, error) {
-	r, err := s.Impl.Sum(req.A, req.B)
+func (s *grpcAddHelperServer) Sum(ctx context.Context, req *proto.SumRequest) (*proto.SumResponse, error) {
+	helper, ok := s.helpers.get(req.CallId)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no Put call %d in progress", req.CallId)
+	}
+
+	r, err := helper.SumContext(ctx, req.A, req.B)
 	if err != nil {
 		return nil, err
 	}
 	return &proto.SumResponse{R: r}, err
 }
+
+// addHelpers holds the AddHelper of each Put call in progress, by call ID.
+type addHelpers struct {
+	mu      sync.Mutex
+	lastID  uint64
+	helpers map[uint64]ContextAddHelper
+}
+
+func newAddHelpers() *addHelpers {
+	return &addHelpers{helpers: make(map[uint64]ContextAddHelper)}
+}
+
+// add holds the helper until the returned release func is called, returning
+// the call ID for the plugin to use.
+func (h *addHelpers) add(helper ContextAddHelper) (uint64, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastID++
+	id := h.lastID
+	h.helpers[id] = helper
+
+	return id, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.helpers, id)
+	}
+}
+
+func (h *addHelpers) get(id uint64) (ContextAddHelper, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	helper, ok := h.helpers[id]
+	return helper, ok
+}
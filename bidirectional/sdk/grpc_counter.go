@@ -6,45 +6,62 @@ package sdk
 
 import (
 	"context"
+	"errors"
+	"sync"
 
 	"github.com/hashicorp/go-plugin"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/mrcook/go-plugin-examples/bidirectional/proto"
 )
 
 // grpcCounterClient is an implementation of CounterStore that talks over RPC.
+// It also implements ContextCounterStore, with gRPC sending any deadline set
+// on the context along with the request.
+//
+// The AddHelpers given to Put are served by a single callback server, which
+// is started when the client is dispensed, and is stopped when the plugin is
+// killed, or Close is called.
 type grpcCounterClient struct {
-	broker *plugin.GRPCBroker
-	client proto.CounterClient
+	client    proto.CounterClient
+	callbacks *callbackServer
 }
 
 func (c *grpcCounterClient) Put(key string, value int64, a AddHelper) error {
-	addHelperServer := &grpcAddHelperServer{Impl: a}
+	return c.PutContext(context.Background(), key, value, NewContextAddHelper(a))
+}
 
-	var s *grpc.Server
-	serverFunc := func(opts []grpc.ServerOption) *grpc.Server {
-		s = grpc.NewServer(opts...)
-		proto.RegisterAddHelperServer(s, addHelperServer)
+func (c *grpcCounterClient) Get(key string) (int64, error) {
+	return c.GetContext(context.Background(), key)
+}
 
-		return s
+// PutContext may be called concurrently, as the AddHelper of each call is
+// given its own call ID on the callback server.
+func (c *grpcCounterClient) PutContext(ctx context.Context, key string, value int64, a ContextAddHelper) error {
+	if c.callbacks.isClosed() {
+		return errClientClosed
 	}
 
-	brokerID := c.broker.NextId()
-	go c.broker.AcceptAndServe(brokerID, serverFunc)
+	callID, release := c.callbacks.helpers.add(a)
+	defer release()
 
-	_, err := c.client.Put(context.Background(), &proto.PutRequest{
-		AddServer: brokerID,
+	_, err := c.client.Put(ctx, &proto.PutRequest{
+		AddServer: c.callbacks.brokerID,
 		Key:       key,
 		Value:     value,
+		CallId:    callID,
 	})
-
-	s.Stop()
 	return err
 }
 
-func (c *grpcCounterClient) Get(key string) (int64, error) {
-	resp, err := c.client.Get(context.Background(), &proto.GetRequest{
+func (c *grpcCounterClient) GetContext(ctx context.Context, key string) (int64, error) {
+	if c.callbacks.isClosed() {
+		return 0, errClientClosed
+	}
+
+	resp, err := c.client.Get(ctx, &proto.GetRequest{
 		Key: key,
 	})
 	if err != nil {
@@ -54,27 +71,259 @@ func (c *grpcCounterClient) Get(key string) (int64, error) {
 	return resp.Value, nil
 }
 
+func (c *grpcCounterClient) Increment(key string, delta int64) (int64, error) {
+	return c.IncrementContext(context.Background(), key, delta)
+}
+
+func (c *grpcCounterClient) CompareAndSwap(key string, old, new int64) (bool, error) {
+	return c.CompareAndSwapContext(context.Background(), key, old, new)
+}
+
+func (c *grpcCounterClient) IncrementContext(ctx context.Context, key string, delta int64) (int64, error) {
+	if c.callbacks.isClosed() {
+		return 0, errClientClosed
+	}
+
+	resp, err := c.client.Increment(ctx, &proto.IncrementRequest{
+		Key:   key,
+		Delta: delta,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Value, nil
+}
+
+func (c *grpcCounterClient) CompareAndSwapContext(ctx context.Context, key string, old, new int64) (bool, error) {
+	if c.callbacks.isClosed() {
+		return false, errClientClosed
+	}
+
+	resp, err := c.client.CompareAndSwap(ctx, &proto.CompareAndSwapRequest{
+		Key: key,
+		Old: old,
+		New: new,
+	})
+	if err != nil {
+		return false, err
+	}
+	return resp.Swapped, nil
+}
+
+// Close stops the callback server, after which the client can no longer be
+// used.
+func (c *grpcCounterClient) Close() error {
+	c.callbacks.close()
+	return nil
+}
+
+var (
+	errClientClosed          = errors.New("the counter store client is closed")
+	errCallbackServerStopped = errors.New("the callback server stopped before it was started")
+)
+
+// callbackServer is the server on which the host application serves the
+// AddHelpers and the HostServices to a plugin. A single server is used for
+// all the calls made by a client, with the plugin connecting to it once.
+type callbackServer struct {
+	brokerID uint32
+	helpers  *addHelpers
+	offered  []string // names of the host services
+
+	mu     sync.Mutex
+	server *grpc.Server
+	closed bool
+}
+
+// startCallbackServer starts serving on a new broker ID. The services are
+// only set when the host application offers them. It returns once the
+// services are registered, or the context is done.
+func startCallbackServer(ctx context.Context, broker *plugin.GRPCBroker, services *HostServices) (*callbackServer, error) {
+	cs := &callbackServer{
+		brokerID: broker.NextId(),
+		helpers:  newAddHelpers(),
+	}
+	if services != nil {
+		cs.offered = services.offered()
+	}
+
+	// The server is created by the broker, so the error of registering the
+	// services is sent back on this channel.
+	registered := make(chan error, 1)
+	serverFunc := func(opts []grpc.ServerOption) *grpc.Server {
+		s := grpc.NewServer(opts...)
+		proto.RegisterAddHelperServer(s, &grpcAddHelperServer{helpers: cs.helpers})
+		var err error
+		if services != nil {
+			err = registerHostServices(s, services)
+		}
+
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+		cs.server = s
+		if cs.closed || err != nil {
+			s.Stop()
+		}
+		registered <- err
+		return s
+	}
+
+	// The server is also stopped by the broker, when the plugin is killed.
+	go func() {
+		broker.AcceptAndServe(cs.brokerID, serverFunc)
+		// The broker returns without calling serverFunc should it fail to
+		// listen for the plugin.
+		select {
+		case registered <- errCallbackServerStopped:
+		default:
+		}
+	}()
+
+	select {
+	case err := <-registered:
+		if err != nil {
+			cs.close()
+			return nil, err
+		}
+		return cs, nil
+	case <-ctx.Done():
+		cs.close()
+		return nil, ctx.Err()
+	}
+}
+
+// connect has the plugin connect to the callback server. This is done when
+// the client is dispensed, as the broker only holds the details of the
+// server for a few seconds.
+func (cs *callbackServer) connect(ctx context.Context, client proto.CounterClient) error {
+	_, err := client.SetHostServices(ctx, &proto.HostServicesRequest{
+		HostServicesServer: cs.brokerID,
+		Services:           cs.offered,
+	})
+	// Plugins built before the host services were added connect on their
+	// first Put instead.
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	return err
+}
+
+func (cs *callbackServer) isClosed() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.closed
+}
+
+func (cs *callbackServer) close() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.closed {
+		return
+	}
+	cs.closed = true
+	if cs.server != nil {
+		cs.server.Stop()
+	}
+}
+
 // grpcCounterServer is the gRPC server that grpcCounterClient talks to.
+//
+// When the Impl is also a ContextCounterStore, the request context is passed
+// on to it, so the plugin can stop work once the host is no longer waiting.
 type grpcCounterServer struct {
 	proto.UnimplementedCounterServer // enable forward-compatibility
 
 	Impl CounterStore
 
 	broker *plugin.GRPCBroker
+
+	// The connections to the callback servers of the host, by broker ID,
+	// which are kept open for as long as the plugin runs.
+	mu    sync.Mutex
+	conns map[uint32]*grpc.ClientConn
 }
 
-func (s *grpcCounterServer) Put(_ context.Context, req *proto.PutRequest) (*proto.Empty, error) {
-	conn, err := s.broker.Dial(req.AddServer)
+// dial returns the connection to the host's callback server, connecting to
+// it on first use.
+func (s *grpcCounterServer) dial(brokerID uint32) (*grpc.ClientConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if conn, ok := s.conns[brokerID]; ok {
+		return conn, nil
+	}
+	conn, err := s.broker.Dial(brokerID)
+	if err != nil {
+		return nil, err
+	}
+	if s.conns == nil {
+		s.conns = make(map[uint32]*grpc.ClientConn)
+	}
+	s.conns[brokerID] = conn
+	return conn, nil
+}
+
+func (s *grpcCounterServer) Put(ctx context.Context, req *proto.PutRequest) (*proto.Empty, error) {
+	conn, err := s.dial(req.AddServer)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
 
-	a := &grpcAddHelperClient{proto.NewAddHelperClient(conn)}
+	a := &grpcAddHelperClient{client: proto.NewAddHelperClient(conn), callID: req.CallId}
+	if impl, ok := s.Impl.(ContextCounterStore); ok {
+		return &proto.Empty{}, impl.PutContext(ctx, req.Key, req.Value, a)
+	}
 	return &proto.Empty{}, s.Impl.Put(req.Key, req.Value, a)
 }
 
-func (s *grpcCounterServer) Get(_ context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
-	v, err := s.Impl.Get(req.Key)
+// SetHostServices connects to the host's callback server, with the host
+// services being passed on to the Impl when it is a HostServicesUser.
+func (s *grpcCounterServer) SetHostServices(_ context.Context, req *proto.HostServicesRequest) (*proto.Empty, error) {
+	conn, err := s.dial(req.HostServicesServer)
+	if err != nil {
+		return nil, err
+	}
+
+	if impl, ok := s.Impl.(HostServicesUser); ok {
+		services, err := newHostServicesClient(conn, req.Services)
+		if err != nil {
+			return nil, err
+		}
+		impl.SetHostServices(services)
+	}
+	return &proto.Empty{}, nil
+}
+
+func (s *grpcCounterServer) Get(ctx context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
+	var v int64
+	var err error
+	if impl, ok := s.Impl.(ContextCounterStore); ok {
+		v, err = impl.GetContext(ctx, req.Key)
+	} else {
+		v, err = s.Impl.Get(req.Key)
+	}
 	return &proto.GetResponse{Value: v}, err
 }
+
+func (s *grpcCounterServer) Increment(ctx context.Context, req *proto.IncrementRequest) (*proto.IncrementResponse, error) {
+	var v int64
+	var err error
+	if impl, ok := s.Impl.(ContextCounterStore); ok {
+		v, err = impl.IncrementContext(ctx, req.Key, req.Delta)
+	} else {
+		v, err = s.Impl.Increment(req.Key, req.Delta)
+	}
+	return &proto.IncrementResponse{Value: v}, err
+}
+
+func (s *grpcCounterServer) CompareAndSwap(ctx context.Context, req *proto.CompareAndSwapRequest) (*proto.CompareAndSwapResponse, error) {
+	var swapped bool
+	var err error
+	if impl, ok := s.Impl.(ContextCounterStore); ok {
+		swapped, err = impl.CompareAndSwapContext(ctx, req.Key, req.Old, req.New)
+	} else {
+		swapped, err = s.Impl.CompareAndSwap(req.Key, req.Old, req.New)
+	}
+	return &proto.CompareAndSwapResponse{Swapped: swapped}, err
+}
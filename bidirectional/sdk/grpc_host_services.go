@@ -0,0 +1,220 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mrcook/go-plugin-examples/bidirectional/proto"
+	kvsdk "github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// registerHostServices registers the services that are set on the server.
+func registerHostServices(s *grpc.Server, services *HostServices) error {
+	if services.Logger != nil {
+		proto.RegisterHostLoggerServer(s, &grpcHostLoggerServer{Impl: services.Logger})
+	}
+	if services.Config != nil {
+		proto.RegisterHostConfigServer(s, &grpcHostConfigServer{Impl: services.Config})
+	}
+	if services.Metrics != nil {
+		proto.RegisterHostMetricsServer(s, &grpcHostMetricsServer{Impl: services.Metrics})
+	}
+	if services.Policy != nil {
+		proto.RegisterHostPolicyServer(s, &grpcHostPolicyServer{Impl: services.Policy})
+	}
+	if services.Storage != nil {
+		// The storage is served using the gRPC server of the KVStore plugin
+		// type, so the plugin can use its gRPC client.
+		storage := &kvsdk.KVPluginGRPC{Impl: services.Storage}
+		if err := storage.GRPCServer(nil, s); err != nil {
+			return fmt.Errorf("registering the storage service: %w", err)
+		}
+	}
+	return nil
+}
+
+// newHostServicesClient returns the HostServices making calls over the
+// connection to the host application, for the services it offers.
+func newHostServicesClient(conn *grpc.ClientConn, offered []string) (*HostServices, error) {
+	services := &HostServices{Logger: hclog.NewNullLogger()}
+	for _, name := range offered {
+		switch name {
+		case loggerServiceName:
+			logger := hclog.NewInterceptLogger(&hclog.LoggerOptions{
+				Output: io.Discard,
+				Level:  hclog.Trace,
+			})
+			logger.RegisterSink(&grpcHostLoggerSink{client: proto.NewHostLoggerClient(conn)})
+			services.Logger = logger
+		case configServiceName:
+			services.Config = &grpcHostConfigClient{client: proto.NewHostConfigClient(conn)}
+		case metricsServiceName:
+			services.Metrics = &grpcHostMetricsClient{client: proto.NewHostMetricsClient(conn)}
+		case policyServiceName:
+			services.Policy = &grpcHostPolicyClient{client: proto.NewHostPolicyClient(conn)}
+		case storageServiceName:
+			storage, err := (&kvsdk.KVPluginGRPC{}).GRPCClient(context.Background(), nil, conn)
+			if err != nil {
+				return nil, err
+			}
+			services.Storage = storage.(kvsdk.KVStore)
+		}
+	}
+	return services, nil
+}
+
+// grpcHostLoggerSink sends the messages written to the plugin's logger to
+// the host application. The plugin is not interrupted when a message can not
+// be sent.
+type grpcHostLoggerSink struct {
+	client proto.HostLoggerClient
+}
+
+func (s *grpcHostLoggerSink) Accept(name string, level hclog.Level, msg string, args ...interface{}) {
+	req := &proto.LogRequest{
+		Level:   level.String(),
+		Name:    name,
+		Message: msg,
+	}
+	for _, arg := range args {
+		req.Args = append(req.Args, fmt.Sprint(arg))
+	}
+	_, _ = s.client.Log(context.Background(), req)
+}
+
+// grpcHostLoggerServer is the gRPC server that grpcHostLoggerSink talks to.
+type grpcHostLoggerServer struct {
+	proto.UnimplementedHostLoggerServer // enable forward-compatibility
+
+	Impl hclog.Logger
+}
+
+func (s *grpcHostLoggerServer) Log(_ context.Context, req *proto.LogRequest) (*proto.Empty, error) {
+	logger := s.Impl
+	if len(req.Name) > 0 {
+		logger = logger.Named(req.Name)
+	}
+
+	args := make([]interface{}, len(req.Args))
+	for i, arg := range req.Args {
+		args[i] = arg
+	}
+
+	level := hclog.LevelFromString(req.Level)
+	if level == hclog.NoLevel {
+		level = hclog.Info
+	}
+	logger.Log(level, req.Message, args...)
+	return &proto.Empty{}, nil
+}
+
+// grpcHostConfigClient is an implementation of ConfigLookup that talks over RPC.
+type grpcHostConfigClient struct {
+	client proto.HostConfigClient
+}
+
+func (c *grpcHostConfigClient) Lookup(ctx context.Context, key string) (string, bool, error) {
+	resp, err := c.client.Lookup(ctx, &proto.ConfigRequest{Key: key})
+	if err != nil {
+		return "", false, err
+	}
+	return resp.Value, resp.Found, nil
+}
+
+// grpcHostConfigServer is the gRPC server that grpcHostConfigClient talks to.
+type grpcHostConfigServer struct {
+	proto.UnimplementedHostConfigServer // enable forward-compatibility
+
+	Impl ConfigLookup
+}
+
+func (s *grpcHostConfigServer) Lookup(ctx context.Context, req *proto.ConfigRequest) (*proto.ConfigResponse, error) {
+	value, found, err := s.Impl.Lookup(ctx, req.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.ConfigResponse{Value: value, Found: found}, nil
+}
+
+// grpcHostMetricsClient is an implementation of MetricsEmitter that talks
+// over RPC.
+type grpcHostMetricsClient struct {
+	client proto.HostMetricsClient
+}
+
+func (c *grpcHostMetricsClient) Emit(ctx context.Context, name string, value float64, labels map[string]string) error {
+	_, err := c.client.Emit(ctx, &proto.MetricRequest{
+		Name:   name,
+		Value:  value,
+		Labels: labels,
+	})
+	return err
+}
+
+// grpcHostMetricsServer is the gRPC server that grpcHostMetricsClient talks to.
+type grpcHostMetricsServer struct {
+	proto.UnimplementedHostMetricsServer // enable forward-compatibility
+
+	Impl MetricsEmitter
+}
+
+func (s *grpcHostMetricsServer) Emit(ctx context.Context, req *proto.MetricRequest) (*proto.Empty, error) {
+	return &proto.Empty{}, s.Impl.Emit(ctx, req.Name, req.Value, req.Labels)
+}
+
+// grpcHostPolicyClient is an implementation of Policy that talks over RPC.
+// When the host application offers no policy, every value is allowed.
+type grpcHostPolicyClient struct {
+	client proto.HostPolicyClient
+}
+
+func (c *grpcHostPolicyClient) Validate(ctx context.Context, key string, value int64) error {
+	_, err := c.client.Validate(ctx, &proto.PolicyRequest{Key: key, Value: value})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	return err
+}
+
+func (c *grpcHostPolicyClient) Clamp(ctx context.Context, key string, value int64) (int64, error) {
+	resp, err := c.client.Clamp(ctx, &proto.PolicyRequest{Key: key, Value: value})
+	if status.Code(err) == codes.Unimplemented {
+		return value, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return resp.Value, nil
+}
+
+// grpcHostPolicyServer is the gRPC server that grpcHostPolicyClient talks to.
+type grpcHostPolicyServer struct {
+	proto.UnimplementedHostPolicyServer // enable forward-compatibility
+
+	Impl Policy
+}
+
+// Validate returns the policy errors with the FailedPrecondition code, which
+// is kept when the plugin returns the error to the host application.
+func (s *grpcHostPolicyServer) Validate(ctx context.Context, req *proto.PolicyRequest) (*proto.Empty, error) {
+	if err := s.Impl.Validate(ctx, req.Key, req.Value); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &proto.Empty{}, nil
+}
+
+func (s *grpcHostPolicyServer) Clamp(ctx context.Context, req *proto.PolicyRequest) (*proto.ClampResponse, error) {
+	value, err := s.Impl.Clamp(ctx, req.Key, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.ClampResponse{Value: value}, nil
+}
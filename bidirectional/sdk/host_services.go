@@ -0,0 +1,102 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+
+	"github.com/hashicorp/go-hclog"
+
+	kvsdk "github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// HostServices is the bundle of services a host application offers to every
+// CounterStore plugin it dispenses, which the plugin calls back to over the
+// GRPCBroker.
+//
+// The host application sets the services on the CounterPlugin in its
+// `pluginMap`, and plugins receive a HostServices making the calls to those
+// of the host by implementing HostServicesUser. A nil service is not offered,
+// and is also nil for the plugin, except for the Logger, which then discards
+// the messages.
+type HostServices struct {
+	// Logger writes to the host's logger, so the messages of plugins are
+	// attributed to them, instead of being written to stderr.
+	Logger hclog.Logger
+
+	// Config looks up the configuration values of the host application.
+	Config ConfigLookup
+
+	// Metrics records the measurements made by plugins.
+	Metrics MetricsEmitter
+
+	// Policy checks the numbers plugins are about to store.
+	Policy Policy
+
+	// Storage is a KVStore plugin running under the host application, which
+	// plugins can store their data in, without knowing which plugin it is.
+	// The host's calls are made to the client dispensed for that plugin,
+	// while plugins receive a client that also implements ContextKVStore.
+	Storage kvsdk.KVStore
+}
+
+// The names of the host services, sent to plugins to tell them which
+// services are offered.
+const (
+	loggerServiceName  = "logger"
+	configServiceName  = "config"
+	metricsServiceName = "metrics"
+	policyServiceName  = "policy"
+	storageServiceName = "storage"
+)
+
+// offered returns the names of the services that are set.
+func (h *HostServices) offered() []string {
+	var names []string
+	if h.Logger != nil {
+		names = append(names, loggerServiceName)
+	}
+	if h.Config != nil {
+		names = append(names, configServiceName)
+	}
+	if h.Metrics != nil {
+		names = append(names, metricsServiceName)
+	}
+	if h.Policy != nil {
+		names = append(names, policyServiceName)
+	}
+	if h.Storage != nil {
+		names = append(names, storageServiceName)
+	}
+	return names
+}
+
+// ConfigLookup is the host service that plugins use to read the host's
+// configuration.
+type ConfigLookup interface {
+	// Lookup returns the value for the key, and whether the key was found.
+	Lookup(ctx context.Context, key string) (string, bool, error)
+}
+
+// MetricsEmitter is the host service that plugins use to record metrics.
+type MetricsEmitter interface {
+	Emit(ctx context.Context, name string, value float64, labels map[string]string) error
+}
+
+// HostServicesUser is implemented by plugins that use the HostServices.
+//
+// SetHostServices is called once, when the host application dispenses the
+// plugin, and before any other requests are made.
+type HostServicesUser interface {
+	SetHostServices(services *HostServices)
+}
+
+// MapConfig is a ConfigLookup for host applications holding their
+// configuration in a map.
+type MapConfig map[string]string
+
+func (c MapConfig) Lookup(_ context.Context, key string) (string, bool, error) {
+	value, ok := c[key]
+	return value, ok, nil
+}
@@ -0,0 +1,52 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"fmt"
+)
+
+// Policy is the host service that plugins query before storing a number, so
+// the business rules of the host application, such as counters never going
+// below zero, are enforced by the host instead of trusting each plugin.
+//
+// When a plugin returns the error from Validate, the host application
+// receives it with the gRPC status code FailedPrecondition.
+type Policy interface {
+	// Validate returns an error when the host does not allow the value to be
+	// stored for the key.
+	Validate(ctx context.Context, key string, value int64) error
+
+	// Clamp returns the value limited to the range the host allows for the
+	// key.
+	Clamp(ctx context.Context, key string, value int64) (int64, error)
+}
+
+// RangePolicy is a Policy allowing the numbers from Min to Max, inclusive,
+// for every key.
+type RangePolicy struct {
+	Min int64
+	Max int64
+}
+
+func (p *RangePolicy) Validate(_ context.Context, key string, value int64) error {
+	if value < p.Min {
+		return fmt.Errorf("value %d for key %q is below the minimum of %d", value, key, p.Min)
+	}
+	if value > p.Max {
+		return fmt.Errorf("value %d for key %q is above the maximum of %d", value, key, p.Max)
+	}
+	return nil
+}
+
+func (p *RangePolicy) Clamp(_ context.Context, _ string, value int64) (int64, error) {
+	if value < p.Min {
+		return p.Min, nil
+	}
+	if value > p.Max {
+		return p.Max, nil
+	}
+	return value, nil
+}
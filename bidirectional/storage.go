@@ -0,0 +1,67 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"github.com/hashicorp/go-plugin"
+
+	"github.com/mrcook/go-plugin-examples/discovery"
+	kvsdk "github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// Storage plugins are the KVStore plugins of the grpc example, with their
+// executables named "kv-<name>", e.g. "kv-go-grpc".
+const storagePluginPattern = "kv-*"
+
+// startStorage finds the named KVStore plugin in the plugin directories, and
+// starts it. The KVStore is offered to the counter plugins as their storage,
+// so they never talk to the KVStore plugin directly.
+func startStorage(pluginName string, args cliArgs) (*plugin.Client, kvsdk.KVStore, error) {
+	registry, err := discovery.Discover(discovery.Config{
+		Dirs:             discovery.SplitDirs(args.pluginDirs),
+		Pattern:          storagePluginPattern,
+		ProtocolVersions: []int{int(kvsdk.HandshakeConfig.ProtocolVersion)},
+		RequireManifest:  true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	storagePlugin, err := registry.Lookup(pluginName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := storagePlugin.Verify(); err != nil {
+		return nil, nil, err
+	}
+
+	// KVStore plugins may use either gRPC or net/rpc.
+	pluginClient := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig: kvsdk.HandshakeConfig,
+		Plugins: plugin.PluginSet{
+			kvsdk.KVStoreGrpcPluginName:   &kvsdk.KVPluginGRPC{},
+			kvsdk.KVStoreNetRpcPluginName: &kvsdk.KVPluginRPC{},
+		},
+		Cmd:              storagePlugin.Cmd(),
+		SecureConfig:     storagePlugin.SecureConfig(),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolNetRPC, plugin.ProtocolGRPC},
+		Logger:           logger(),
+	})
+
+	client, err := pluginClient.Client()
+	if err != nil {
+		pluginClient.Kill()
+		return nil, nil, err
+	}
+
+	name := kvsdk.KVStoreGrpcPluginName
+	if pluginClient.Protocol() == plugin.ProtocolNetRPC {
+		name = kvsdk.KVStoreNetRpcPluginName
+	}
+	raw, err := client.Dispense(name)
+	if err != nil {
+		pluginClient.Kill()
+		return nil, nil, err
+	}
+	return pluginClient, raw.(kvsdk.KVStore), nil
+}
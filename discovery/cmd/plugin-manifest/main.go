@@ -0,0 +1,61 @@
+// A command for writing the manifest for a plugin executable, which is saved
+// next to the executable, e.g. "kv-go-grpc.manifest.json".
+//
+// Usage:
+//
+//	plugin-manifest --name=go-grpc --version=1.0.0 --protocols=1 ./kv-go-grpc
+//
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mrcook/go-plugin-examples/discovery"
+)
+
+func main() {
+	name := flag.String("name", "", "Plugin name, used by host applications to select the plugin.")
+	version := flag.String("version", "", "Plugin release version.")
+	protocols := flag.String("protocols", "", "Comma separated list of supported protocol versions.")
+	flag.Parse()
+
+	executable := flag.Arg(0)
+	if len(executable) == 0 || len(*name) == 0 || len(*protocols) == 0 {
+		fmt.Println("usage: plugin-manifest --name=NAME --version=VERSION --protocols=1,2 EXECUTABLE")
+		os.Exit(1)
+	}
+
+	var versions []int
+	for _, p := range strings.Split(*protocols, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			fmt.Println("invalid protocol version:", p)
+			os.Exit(1)
+		}
+		versions = append(versions, v)
+	}
+
+	checksum, err := discovery.FileChecksum(executable)
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		os.Exit(1)
+	}
+
+	err = discovery.WriteManifest(executable+discovery.ManifestExt, &discovery.Manifest{
+		Name:      *name,
+		Version:   *version,
+		Protocols: versions,
+		SHA256:    hex.EncodeToString(checksum),
+	})
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,283 @@
+// Package discovery finds plugin executables on disk so that host applications
+// can select a plugin by name, rather than hardcoding the path to its binary.
+//
+// Plugins are found by scanning a list of directories for executables whose
+// filename matches a naming pattern. For each match the plugin name, protocol
+// versions, and SHA-256 checksum of the executable are recorded in a Registry.
+//
+// When a Manifest is shipped next to the executable, its details are recorded
+// instead, and the checksum it declares must match that of the executable for
+// the plugin to be launched.
+//
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+package discovery
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-plugin"
+)
+
+// Config defines where, and how, plugins are discovered.
+type Config struct {
+	// Dirs are the directories to search, in order. When the same plugin name
+	// is found in more than one directory, the first one found is used.
+	Dirs []string
+
+	// Pattern is a filepath.Match pattern that plugin filenames must match,
+	// e.g. "kv-*". The plugin name is the part of the filename matched by the
+	// wildcards, so "kv-go-grpc" is registered as "go-grpc".
+	//
+	// A name ending in "_v<N>", e.g. "kv-go-grpc_v2", declares the protocol
+	// version the plugin supports, and is registered as "go-grpc".
+	Pattern string
+
+	// ProtocolVersions are recorded for plugins that do not declare their own
+	// protocol version. These are usually the versions the host supports, as
+	// plugins declaring none of them are skipped.
+	ProtocolVersions []int
+
+	// RequireManifest skips any executable without a manifest.
+	RequireManifest bool
+}
+
+// Plugin is a plugin executable found during discovery.
+type Plugin struct {
+	Name             string    // plugin name, used when selecting the plugin
+	Path             string    // absolute path to the plugin executable
+	ProtocolVersions []int     // protocol versions supported by the plugin
+	Checksum         []byte    // expected SHA-256 checksum of the executable
+	Manifest         *Manifest // the plugin manifest, if one was found
+}
+
+// Cmd returns the command used by plugin.ClientConfig to launch the plugin.
+func (p *Plugin) Cmd() *exec.Cmd {
+	return exec.Command(p.Path)
+}
+
+// SecureConfig returns the configuration used by plugin.ClientConfig to verify
+// the checksum of the executable before launching the plugin.
+func (p *Plugin) SecureConfig() *plugin.SecureConfig {
+	return &plugin.SecureConfig{
+		Checksum: p.Checksum,
+		Hash:     sha256.New(),
+	}
+}
+
+// Verify checks the executable against the expected checksum, returning an
+// error when they do not match. go-plugin also performs this check when
+// launching the plugin, but only returns a generic error.
+func (p *Plugin) Verify() error {
+	ok, err := p.SecureConfig().Check(p.Path)
+	if err != nil {
+		return fmt.Errorf("verifying plugin %q: %w", p.Name, err)
+	}
+	if !ok {
+		if p.Manifest != nil {
+			return fmt.Errorf("plugin %q refused: checksum of %s does not match its manifest", p.Name, p.Path)
+		}
+		return fmt.Errorf("plugin %q refused: %s has changed since it was discovered", p.Name, p.Path)
+	}
+	return nil
+}
+
+// Supports reports whether the plugin supports the given protocol version.
+// Plugins without any recorded versions are assumed to support all versions,
+// with the final say being the go-plugin handshake.
+func (p *Plugin) Supports(version int) bool {
+	if len(p.ProtocolVersions) == 0 {
+		return true
+	}
+	for _, v := range p.ProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// Discover scans the configured directories and returns a Registry of all the
+// plugins found. Directories that do not exist are skipped.
+//
+// A plugin that can not be used, such as one with an invalid manifest, or
+// that supports none of the configured protocol versions, is skipped, with
+// its error recorded in the Registry, so the other plugins can still be used.
+func Discover(cfg Config) (*Registry, error) {
+	if _, err := filepath.Match(cfg.Pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid plugin pattern %q: %w", cfg.Pattern, err)
+	}
+
+	reg := NewRegistry()
+
+	for _, dir := range cfg.Dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading plugin directory: %w", err)
+		}
+
+		for _, entry := range entries {
+			if ok, _ := filepath.Match(cfg.Pattern, entry.Name()); !ok {
+				continue
+			}
+
+			// Until its manifest is read, the plugin is named after its file.
+			name, _ := parseFilename(cfg.Pattern, entry.Name())
+
+			p, err := discoverPlugin(cfg, dir, entry)
+			if p != nil {
+				name = p.Name
+				if err == nil {
+					err = checkProtocols(cfg, p)
+				}
+			}
+			if err != nil {
+				reg.skip(name, err)
+				continue
+			}
+			if p == nil {
+				continue
+			}
+			if _, found := reg.plugins[p.Name]; !found {
+				reg.Add(p)
+			}
+		}
+	}
+
+	return reg, nil
+}
+
+// discoverPlugin returns the plugin for the directory entry. A nil plugin is
+// returned when the entry is not an executable, or the config requires a
+// manifest but the executable has none.
+func discoverPlugin(cfg Config, dir string, entry os.DirEntry) (*Plugin, error) {
+	info, err := entry.Info()
+	if err != nil {
+		return nil, err
+	}
+	if !isExecutable(info) {
+		return nil, nil
+	}
+
+	path, err := filepath.Abs(filepath.Join(dir, entry.Name()))
+	if err != nil {
+		return nil, err
+	}
+	return newPlugin(cfg, path)
+}
+
+// checkProtocols returns an error when the plugin supports none of the
+// configured protocol versions, so it is refused at discovery, rather than
+// when the plugin is launched.
+func checkProtocols(cfg Config, p *Plugin) error {
+	if len(cfg.ProtocolVersions) == 0 {
+		return nil
+	}
+	for _, v := range cfg.ProtocolVersions {
+		if p.Supports(v) {
+			return nil
+		}
+	}
+	return fmt.Errorf("plugin %q refused: %s supports protocol versions %v, but only %v are supported",
+		p.Name, p.Path, p.ProtocolVersions, cfg.ProtocolVersions)
+}
+
+// newPlugin returns the plugin for the executable at the given path, using
+// the details from its manifest when present. A nil plugin is returned when
+// the config requires a manifest but the executable has none.
+func newPlugin(cfg Config, path string) (*Plugin, error) {
+	manifest, err := ReadManifest(path + ManifestExt)
+	if os.IsNotExist(err) {
+		if cfg.RequireManifest {
+			return nil, nil
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if manifest != nil {
+		checksum, err := manifest.Checksum()
+		if err != nil {
+			return nil, err
+		}
+		return &Plugin{
+			Name:             manifest.Name,
+			Path:             path,
+			ProtocolVersions: manifest.Protocols,
+			Checksum:         checksum,
+			Manifest:         manifest,
+		}, nil
+	}
+
+	name, versions := parseFilename(cfg.Pattern, filepath.Base(path))
+	if len(versions) == 0 {
+		versions = cfg.ProtocolVersions
+	}
+
+	checksum, err := FileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Plugin{
+		Name:             name,
+		Path:             path,
+		ProtocolVersions: versions,
+		Checksum:         checksum,
+	}, nil
+}
+
+// SplitDirs splits a list of directories joined by the OS specific path list
+// separator, as used by the PATH environment variable.
+func SplitDirs(dirs string) []string {
+	return filepath.SplitList(dirs)
+}
+
+// Only regular files with an executable bit set are considered plugins.
+func isExecutable(info os.FileInfo) bool {
+	return info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+// parseFilename strips the literal prefix and suffix of the pattern from the
+// filename, along with any "_v<N>" protocol version.
+func parseFilename(pattern, filename string) (string, []int) {
+	name := filename
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		name = strings.TrimPrefix(name, pattern[:i])
+	}
+	if i := strings.LastIndexAny(pattern, `*?]`); i >= 0 {
+		name = strings.TrimSuffix(name, pattern[i+1:])
+	}
+
+	if i := strings.LastIndex(name, "_v"); i > 0 {
+		if v, err := strconv.Atoi(name[i+2:]); err == nil {
+			return name[:i], []int{v}
+		}
+	}
+
+	return name, nil
+}
+
+// FileChecksum returns the SHA-256 checksum of the file contents.
+func FileChecksum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
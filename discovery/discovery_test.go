@@ -0,0 +1,123 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package discovery
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePlugin writes an executable to the directory, along with its manifest
+// when one is given, returning the path of the executable.
+func writePlugin(t *testing.T, dir, filename string, manifest *Manifest) string {
+	t.Helper()
+	path := filepath.Join(dir, filename)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if manifest != nil {
+		sum, err := FileChecksum(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest.SHA256 = hex.EncodeToString(sum)
+		if err := WriteManifest(path+ManifestExt, manifest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestDiscoverSkipsBadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "kv-good", &Manifest{Name: "good", Version: "1.0.0", Protocols: []int{1}})
+	writePlugin(t, dir, "kv-old", &Manifest{Name: "old", Version: "1.0.0", Protocols: []int{0}})
+	writePlugin(t, dir, "kv-broken", nil)
+	if err := os.WriteFile(filepath.Join(dir, "kv-broken"+ManifestExt), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reg, err := Discover(Config{
+		Dirs:             []string{dir},
+		Pattern:          "kv-*",
+		ProtocolVersions: []int{1},
+		RequireManifest:  true,
+	})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	if got := strings.Join(reg.Names(), ","); got != "good" {
+		t.Errorf("Names() = %q, want %q", got, "good")
+	}
+	if _, err := reg.Lookup("good"); err != nil {
+		t.Errorf("Lookup(good) error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		wantErr string
+	}{
+		{"broken", "invalid plugin manifest"},
+		{"old", "supports protocol versions [0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := reg.Errors()[tt.name]; err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Errors()[%q] = %v, want containing %q", tt.name, err, tt.wantErr)
+			}
+			if _, err := reg.Lookup(tt.name); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Lookup(%q) error = %v, want containing %q", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscoverFirstDirWins(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	want := writePlugin(t, first, "kv-go-grpc_v2", nil)
+	writePlugin(t, second, "kv-go-grpc", nil)
+
+	reg, err := Discover(Config{
+		Dirs:             []string{first, second},
+		Pattern:          "kv-*",
+		ProtocolVersions: []int{1, 2},
+	})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	p, err := reg.Lookup("go-grpc")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if p.Path != want {
+		t.Errorf("Path = %q, want %q", p.Path, want)
+	}
+	if !p.Supports(2) || p.Supports(1) {
+		t.Errorf("ProtocolVersions = %v, want [2]", p.ProtocolVersions)
+	}
+}
+
+func TestParseFilename(t *testing.T) {
+	tests := []struct {
+		pattern, filename string
+		name              string
+		versions          []int
+	}{
+		{"kv-*", "kv-go-grpc", "go-grpc", nil},
+		{"kv-*", "kv-go-grpc_v2", "go-grpc", []int{2}},
+		{"kv-*", "kv-go_vx", "go_vx", nil},
+		{"*_plugin", "hello_plugin", "hello", nil},
+	}
+	for _, tt := range tests {
+		name, versions := parseFilename(tt.pattern, tt.filename)
+		if name != tt.name || len(versions) != len(tt.versions) || (len(versions) > 0 && versions[0] != tt.versions[0]) {
+			t.Errorf("parseFilename(%q, %q) = %q, %v, want %q, %v", tt.pattern, tt.filename, name, versions, tt.name, tt.versions)
+		}
+	}
+}
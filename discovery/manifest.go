@@ -0,0 +1,77 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package discovery
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ManifestExt is appended to the plugin executable filename to give the
+// filename of its manifest, e.g. "kv-go-grpc.manifest.json".
+const ManifestExt = ".manifest.json"
+
+// Manifest is shipped next to a plugin executable, and declares the details of
+// the plugin, along with the SHA-256 checksum of the executable.
+//
+// The host application verifies the checksum before launching the plugin, so
+// manifests should come from a trusted source, such as the plugin release.
+type Manifest struct {
+	Name      string `json:"name"`      // plugin name, used when selecting the plugin
+	Version   string `json:"version"`   // plugin release version, e.g. "1.0.0"
+	Protocols []int  `json:"protocols"` // supported protocol versions
+	SHA256    string `json:"sha256"`    // hex encoded checksum of the executable
+}
+
+// ReadManifest reads and validates the manifest file at the given path.
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid plugin manifest %s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid plugin manifest %s: %w", path, err)
+	}
+
+	return m, nil
+}
+
+// WriteManifest writes the manifest, as indented JSON, to the given path.
+func WriteManifest(path string, m *Manifest) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Checksum returns the decoded SHA-256 checksum.
+func (m *Manifest) Checksum() ([]byte, error) {
+	return hex.DecodeString(m.SHA256)
+}
+
+func (m *Manifest) validate() error {
+	if len(m.Name) == 0 {
+		return errors.New("name must be present")
+	}
+	if len(m.Protocols) == 0 {
+		return errors.New("at least one protocol version must be present")
+	}
+	if sum, err := m.Checksum(); err != nil || len(sum) != 32 {
+		return errors.New("sha256 must be a hex encoded SHA-256 checksum")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Registry holds the discovered plugins, keyed by plugin name.
+type Registry struct {
+	plugins map[string]*Plugin
+	errs    map[string]error // the errors of the skipped plugins
+}
+
+// NewRegistry returns an empty registry. Most host applications will use the
+// registry returned by Discover instead.
+func NewRegistry() *Registry {
+	return &Registry{
+		plugins: make(map[string]*Plugin),
+		errs:    make(map[string]error),
+	}
+}
+
+// Add registers the plugin, replacing any existing plugin of the same name.
+func (r *Registry) Add(p *Plugin) {
+	r.plugins[p.Name] = p
+}
+
+// skip records the error of a plugin skipped during discovery. Only the first
+// error for each plugin name is kept, as with the plugins themselves.
+func (r *Registry) skip(name string, err error) {
+	if _, found := r.errs[name]; !found {
+		r.errs[name] = err
+	}
+}
+
+// Lookup returns the plugin registered with the given name. If no such plugin
+// was found, the error is that of the plugin being skipped during discovery,
+// or else lists the names of the plugins that are available.
+func (r *Registry) Lookup(name string) (*Plugin, error) {
+	if p, ok := r.plugins[name]; ok {
+		return p, nil
+	}
+	if err, ok := r.errs[name]; ok {
+		return nil, err
+	}
+	if len(r.plugins) == 0 {
+		return nil, fmt.Errorf("plugin %q not found: no plugins were discovered", name)
+	}
+	return nil, fmt.Errorf("plugin %q not found, available plugins: %s", name, strings.Join(r.Names(), ", "))
+}
+
+// Names returns the names of all registered plugins, sorted alphabetically.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.plugins))
+	for name := range r.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Plugins returns all registered plugins, sorted by name.
+func (r *Registry) Plugins() []*Plugin {
+	plugins := make([]*Plugin, 0, len(r.plugins))
+	for _, name := range r.Names() {
+		plugins = append(plugins, r.plugins[name])
+	}
+	return plugins
+}
+
+// Errors returns the errors of the plugins skipped during discovery, keyed by
+// plugin name. A plugin found in a later directory may have been registered
+// in place of one that was skipped.
+func (r *Registry) Errors() map[string]error {
+	errs := make(map[string]error, len(r.errs))
+	for name, err := range r.errs {
+		errs[name] = err
+	}
+	return errs
+}
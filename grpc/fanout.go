@@ -0,0 +1,140 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/supervisor"
+)
+
+// fanOutResult is the outcome of running the command on one of the plugins.
+type fanOutResult struct {
+	pluginName string
+	supervisor *supervisor.Supervisor
+	startup    time.Duration // how long the plugin took to start
+	latency    time.Duration // how long the command took
+	output     string
+	err        error
+}
+
+// fanOut starts all the plugins, then runs the command on each of them
+// concurrently, printing the result and latency for each plugin. This is
+// useful for comparing plugins, e.g. when migrating to another backend.
+//
+// The exit code is returned, which is an error when any plugin fails.
+func fanOut(args cliArgs) int {
+	results := make([]*fanOutResult, len(args.pluginNames))
+	for i, name := range args.pluginNames {
+		results[i] = &fanOutResult{pluginName: name}
+	}
+
+	// The plugins are all started before running the command, so the command
+	// is made at the same time on each plugin.
+	eachResult(results, func(r *fanOutResult) {
+		start := time.Now()
+		r.supervisor, r.err = startPlugin(r.pluginName, args.pluginDirs)
+		r.startup = time.Since(start)
+	})
+	defer eachResult(results, func(r *fanOutResult) {
+		if r.supervisor != nil {
+			r.supervisor.Kill()
+		}
+	})
+
+	eachResult(results, func(r *fanOutResult) {
+		if r.err != nil {
+			return
+		}
+		kv := sdk.NewSupervisedKVStore(r.supervisor)
+
+		ctx, cancel := context.WithTimeout(context.Background(), args.timeout)
+		defer cancel()
+
+		start := time.Now()
+		r.output, r.err = runCommand(ctx, kv, args)
+		r.latency = time.Since(start)
+	})
+
+	exitCode := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PLUGIN\tSTARTUP\tLATENCY\tRESULT")
+	for _, r := range results {
+		output := r.output
+		if r.err != nil {
+			// Some errors, such as a failed handshake, span several lines.
+			output = "Error: " + strings.Join(strings.Fields(r.err.Error()), " ")
+			exitCode = exitError
+		}
+
+		// The command is not run on a plugin that failed to start.
+		latency := "-"
+		if r.latency > 0 {
+			latency = r.latency.Round(time.Microsecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.pluginName, r.startup.Round(time.Microsecond), latency, output)
+	}
+	tw.Flush()
+
+	return exitCode
+}
+
+// eachResult calls fn for every result concurrently, returning once all the
+// calls are done.
+func eachResult(results []*fanOutResult, fn func(r *fanOutResult)) {
+	var wg sync.WaitGroup
+	for _, r := range results {
+		wg.Add(1)
+		go func(r *fanOutResult) {
+			defer wg.Done()
+			fn(r)
+		}(r)
+	}
+	wg.Wait()
+}
+
+// runCommand runs the command on a single plugin, returning the result as a
+// single line, so that the results of each plugin can be shown in a table.
+func runCommand(ctx context.Context, kv sdk.ContextKVStore, args cliArgs) (string, error) {
+	switch args.command {
+	case "get":
+		value, err := kv.GetContext(ctx, args.key)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Quote(string(value)), nil
+	case "put":
+		return "ok", putValue(ctx, kv, args)
+	case "list":
+		keys, err := kv.ListContext(ctx, args.key)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(keys, " "), nil
+	case "delete":
+		return "ok", kv.DeleteContext(ctx, args.key)
+	case "has":
+		exists, err := kv.HasContext(ctx, args.key)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(exists), nil
+	case "import":
+		n, err := importFile(ctx, kv, args.key)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("imported %d keys", n), nil
+	default:
+		return "", fmt.Errorf("the '%s' command can only use a single plugin", args.command)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// putFile streams the contents of the file to the plugin as the value of the
+// key, so files of any size can be stored.
+func putFile(ctx context.Context, kv sdk.ContextKVStore, key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return sdk.PutStream(ctx, kv, key, f)
+}
+
+// getFile streams the value of the key from the plugin into the file. The
+// file is removed should the value not be written in full.
+func getFile(ctx context.Context, kv sdk.ContextKVStore, key, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = sdk.GetStream(ctx, kv, key, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
@@ -3,13 +3,20 @@ module github.com/mrcook/go-plugin-examples/grpc
 go 1.20
 
 require (
+	github.com/fsnotify/fsnotify v1.6.0
 	github.com/hashicorp/go-hclog v1.5.0
 	github.com/hashicorp/go-plugin v1.4.9
+	github.com/mrcook/go-plugin-examples/discovery v0.0.0
+	github.com/mrcook/go-plugin-examples/repl v0.0.0
+	github.com/mrcook/go-plugin-examples/storage v0.0.0
+	github.com/mrcook/go-plugin-examples/supervisor v0.0.0
+	go.etcd.io/bbolt v1.3.7
 	google.golang.org/grpc v1.54.0
 	google.golang.org/protobuf v1.30.0
 )
 
 require (
+	github.com/chzyer/readline v1.5.1 // indirect
 	github.com/fatih/color v1.15.0 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/hashicorp/yamux v0.1.1 // indirect
@@ -17,9 +24,15 @@ require (
 	github.com/mattn/go-isatty v0.0.18 // indirect
 	github.com/mitchellh/go-testing-interface v1.14.1 // indirect
 	github.com/oklog/run v1.1.0 // indirect
-	github.com/stretchr/testify v1.8.1 // indirect
 	golang.org/x/net v0.9.0 // indirect
 	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
 )
+
+replace (
+	github.com/mrcook/go-plugin-examples/discovery => ../discovery
+	github.com/mrcook/go-plugin-examples/repl => ../repl
+	github.com/mrcook/go-plugin-examples/storage => ../storage
+	github.com/mrcook/go-plugin-examples/supervisor => ../supervisor
+)
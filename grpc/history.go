@@ -0,0 +1,45 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// printHistory prints the revisions kept of the key, oldest first, with the
+// last being the current value.
+func printHistory(ctx context.Context, kv sdk.ContextKVStore, key string) error {
+	revisions, err := sdk.History(ctx, kv, key)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		fmt.Println("no revisions are kept of the key")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REVISION\tTIME\tSIZE")
+	for _, r := range revisions {
+		fmt.Fprintf(tw, "%d\t%s\t%d\n", r.Number, r.Time.Format(time.RFC3339), r.Size)
+	}
+	return tw.Flush()
+}
+
+// parseRevision returns the revision number given for the get-revision and
+// rollback commands.
+func parseRevision(s string) (int64, error) {
+	revision, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || revision < 1 {
+		return 0, fmt.Errorf("revision must be a number of 1 or more, given '%s'", s)
+	}
+	return revision, nil
+}
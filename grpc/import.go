@@ -0,0 +1,92 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// importFile puts the key/value pairs of the file in the KV store as a single
+// batch, returning how many keys were put.
+func importFile(ctx context.Context, kv sdk.ContextKVStore, path string) (int, error) {
+	items, err := readImportFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := sdk.PutMany(ctx, kv, items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+// readImportFile reads the key/value pairs of a JSON or CSV file, chosen by
+// the file extension.
+//
+// A JSON file holds an object of string values, e.g. {"hello": "world"}, with
+// the keys being put in sorted order. Each row of a CSV file holds a key and
+// its value, with a first row of "key,value" being treated as a header.
+func readImportFile(path string) ([]sdk.KeyValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return readImportJSON(f)
+	case ".csv":
+		return readImportCSV(f)
+	default:
+		return nil, fmt.Errorf("import file must be a .json or .csv file, given '%s'", path)
+	}
+}
+
+func readImportJSON(r io.Reader) ([]sdk.KeyValue, error) {
+	var pairs map[string]string
+	if err := json.NewDecoder(r).Decode(&pairs); err != nil {
+		return nil, fmt.Errorf("import file must hold a JSON object of string values: %w", err)
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := make([]sdk.KeyValue, len(keys))
+	for i, key := range keys {
+		items[i] = sdk.KeyValue{Key: key, Value: []byte(pairs[key])}
+	}
+	return items, nil
+}
+
+func readImportCSV(r io.Reader) ([]sdk.KeyValue, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("import file must hold a key and value on each row: %w", err)
+	}
+	if len(records) > 0 && records[0][0] == "key" && records[0][1] == "value" {
+		records = records[1:]
+	}
+
+	items := make([]sdk.KeyValue, len(records))
+	for i, record := range records {
+		items[i] = sdk.KeyValue{Key: record[0], Value: []byte(record[1])}
+	}
+	return items, nil
+}
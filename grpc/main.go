@@ -10,148 +10,461 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 
+	"github.com/mrcook/go-plugin-examples/discovery"
 	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/supervisor"
 )
 
-// The executable for each plugin.
+// Go plugin executables are named "kv-<name>", e.g. "kv-go-grpc", and are
+// found in the plugin directories by discovery.
+const pluginPattern = "kv-*"
+
+// The shortcut names of the discovered Go plugins, used by the --grpc and --rpc
+// flags, and the switch-plugin command of the REPL.
+var pluginAliases = map[string]string{
+	"grpc": "go-grpc",
+	"rpc":  "go-netrpc",
+	"bolt": "go-bolt",
+}
+
+// pluginMap is the map of plugins we can dispense. The same PluginSet is used
+// for every plugin started by the application.
+var pluginMap = plugin.PluginSet{
+	sdk.KVStoreGrpcPluginName:   &sdk.KVPluginGRPC{},
+	sdk.KVStoreNetRpcPluginName: &sdk.KVPluginRPC{},
+}
+
+// The Python plugin is run by the Python interpreter so is not discovered.
 const (
-	grpcPluginExecutable   = "./kv-go-grpc"
-	rpcPluginExecutable    = "./kv-go-netrpc"
+	pythonPluginName       = "python"
 	pythonPluginExecutable = "python plugin-python/plugin.py"
 )
 
 func main() {
-	// Fetch the plugin type, command, and key/value data from the CLI args.
+	// Fetch the plugin name, command, and key/value data from the CLI args.
 	args := parseFlags()
 
-	// Configure which plugin to use!
-	var pluginName, pluginExecutable string
-	if args.plugin == "grpc" {
-		pluginName = sdk.KVStoreGrpcPluginName
-		pluginExecutable = grpcPluginExecutable
-	} else if args.plugin == "rpc" {
-		pluginName = sdk.KVStoreNetRpcPluginName
-		pluginExecutable = rpcPluginExecutable
-	} else if args.plugin == "python" {
-		pluginName = sdk.KVStoreGrpcPluginName
-		pluginExecutable = pythonPluginExecutable
-	}
-
-	// PluginMap is the map of plugins we can dispense.
-	pluginMap := map[string]plugin.Plugin{
-		sdk.KVStoreGrpcPluginName:   &sdk.KVPluginGRPC{},
-		sdk.KVStoreNetRpcPluginName: &sdk.KVPluginRPC{},
+	// With several plugins, the command is run on all of them at once.
+	if len(args.pluginNames) > 0 {
+		os.Exit(fanOut(args))
 	}
 
-	// Configure a new plugin client:
-	// - HandshakeConfig: is required
-	// - Plugins: is a map containing the supported plugins and their plugin.Plugin implementations
-	// - Cmd: points to the compiled binary of your plugin
-	// - AllowedProtocols: by default only net/rpc is allowed, so add gRPC support
-	pluginClient := plugin.NewClient(&plugin.ClientConfig{
-		HandshakeConfig:  sdk.HandshakeConfig,
-		Plugins:          pluginMap,
-		Cmd:              exec.Command("sh", "-c", pluginExecutable),
-		AllowedProtocols: []plugin.Protocol{plugin.ProtocolNetRPC, plugin.ProtocolGRPC},
-		Logger:           logger(),
-	})
-	defer pluginClient.Kill()
+	// With replicas, the command is made to a store replicated across them.
+	if len(args.replicaNames) > 0 {
+		kv, stop, err := startReplicas(args)
+		if err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+		defer stop()
 
-	// Get the client for RPC communication.
-	client, err := pluginClient.Client()
-	if err != nil {
-		fmt.Println("Error:", err.Error())
-		os.Exit(1)
+		if err := doCommand(kv, args); err != nil {
+			stop()
+			exitWithError(err)
+		}
+		return
 	}
 
-	// Request the plugin.
-	raw, err := client.Dispense(pluginName)
+	// Start the plugin, which is restarted should it crash.
+	kvSupervisor, err := startPlugin(args.pluginName, args.pluginDirs)
 	if err != nil {
 		fmt.Println("Error:", err.Error())
 		os.Exit(1)
 	}
+	defer kvSupervisor.Kill()
 
-	// As Dispense() returns an interface, we need to cast it to the plugin
-	// type supported by the host application, which in our case is a KV store.
-	// This feels like a normal interface implementation, but is in fact
+	// The supervised KV store makes each call to the current plugin. This
+	// feels like a normal interface implementation, but is in fact
 	// communicating over an RPC connection.
-	kv := raw.(sdk.KVStore)
+	// The context-aware methods are used so a hung plugin can't block forever.
+	kv := sdk.NewSupervisedKVStore(kvSupervisor)
 
-	// Call the appropriate method based on that requested by the user.
-	if args.command == "get" {
-		result, err := kv.Get(args.key)
+	// The serve command keeps the plugin running until interrupted.
+	if args.command == "serve" {
+		err := serve(kv, args)
+		kvSupervisor.Kill()
 		if err != nil {
 			fmt.Println("Error:", err.Error())
 			os.Exit(1)
 		}
+		return
+	}
+
+	// The watch command prints the changes to the keys until interrupted.
+	if args.command == "watch" {
+		err := watch(kv, args)
+		kvSupervisor.Kill()
+		if err != nil {
+			exitWithError(err)
+		}
+		return
+	}
+
+	// The repl command reads commands until the user exits, and may switch
+	// to another plugin, so it stops the plugin itself.
+	if args.command == "repl" {
+		if err := runREPL(kvSupervisor, args); err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := doCommand(kv, args); err != nil {
+		kvSupervisor.Kill()
+		exitWithError(err)
+	}
+}
+
+// doCommand calls the method of the KV store for the requested command, and
+// prints the result. The error is returned, so the caller can stop the plugins
+// before exiting.
+func doCommand(kv sdk.ContextKVStore, args cliArgs) error {
+	// The deadline is sent along with the request to the plugin.
+	ctx, cancel := context.WithTimeout(context.Background(), args.timeout)
+	defer cancel()
+
+	// Call the appropriate method based on that requested by the user.
+	if args.command == "get" {
+		result, err := kv.GetContext(ctx, args.key)
+		if err != nil {
+			return err
+		}
 
 		// Let's see what the plugin returns!
 		fmt.Println(string(result))
 	} else if args.command == "put" {
-		err := kv.Put(args.key, []byte(args.value))
+		err := putValue(ctx, kv, args)
 		if err != nil {
-			fmt.Println("Error:", err.Error())
-			os.Exit(1)
+			return err
+		}
+	} else if args.command == "list" {
+		// The key is used as the prefix of the keys to list.
+		keys, err := kv.ListContext(ctx, args.key)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+	} else if args.command == "delete" {
+		err := kv.DeleteContext(ctx, args.key)
+		if err != nil {
+			return err
+		}
+	} else if args.command == "has" {
+		exists, err := kv.HasContext(ctx, args.key)
+		if err != nil {
+			return err
+		}
+		fmt.Println(exists)
+	} else if args.command == "import" {
+		// The key is used as the path of the file to import.
+		n, err := importFile(ctx, kv, args.key)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("imported %d keys\n", n)
+	} else if args.command == "put-file" {
+		// The value is used as the path of the file to store.
+		if err := putFile(ctx, kv, args.key, args.value); err != nil {
+			return err
+		}
+	} else if args.command == "get-file" {
+		// The value is used as the path of the file to write.
+		if err := getFile(ctx, kv, args.key, args.value); err != nil {
+			return err
+		}
+	} else if args.command == "history" {
+		if err := printHistory(ctx, kv, args.key); err != nil {
+			return err
+		}
+	} else if args.command == "get-revision" {
+		value, err := sdk.GetRevision(ctx, kv, args.key, args.revision)
+		if err != nil {
+			return err
 		}
+		fmt.Println(string(value))
+	} else if args.command == "rollback" {
+		if err := sdk.Rollback(ctx, kv, args.key, args.revision); err != nil {
+			return err
+		}
+	} else if args.command == "txn" {
+		if err := commitTxn(ctx, kv, args.txn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putValue puts the value of the key, which expires once the --ttl has passed,
+// when given.
+func putValue(ctx context.Context, kv sdk.ContextKVStore, args cliArgs) error {
+	if args.ttl > 0 {
+		return sdk.PutWithTTL(ctx, kv, args.key, []byte(args.value), args.ttl)
+	}
+	return kv.PutContext(ctx, args.key, []byte(args.value))
+}
+
+// Exit codes returned by the application, so that scripts can tell a missing
+// key apart from other failures.
+const (
+	exitError            = 1
+	exitNotFound         = 2
+	exitInvalidKey       = 3
+	exitPermissionDenied = 4
+	exitPartiallyApplied = 5
+)
+
+// resolvePluginName returns the plugin name for one of the pluginAliases,
+// otherwise the name is returned unchanged.
+func resolvePluginName(name string) string {
+	if pluginName, ok := pluginAliases[name]; ok {
+		return pluginName
+	}
+	return name
+}
+
+// splitPluginNames returns the plugin names of a comma separated list.
+func splitPluginNames(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		names = append(names, resolvePluginName(strings.TrimSpace(name)))
+	}
+	return names
+}
+
+// startPlugin starts the named plugin, found in the plugin directories, using
+// a supervisor.
+func startPlugin(pluginName, pluginDirs string) (*supervisor.Supervisor, error) {
+	// Configure which plugin to use! Discovered plugins must have a manifest,
+	// and the executable must match its checksum. A new command is needed each
+	// time the plugin is started.
+	var pluginCmd func() *exec.Cmd
+	var secureConfig func() *plugin.SecureConfig
+	if pluginName == pythonPluginName {
+		pluginCmd = func() *exec.Cmd { return exec.Command("sh", "-c", pythonPluginExecutable) }
+		secureConfig = func() *plugin.SecureConfig { return nil }
+	} else {
+		registry, err := discovery.Discover(discovery.Config{
+			Dirs:             discovery.SplitDirs(pluginDirs),
+			Pattern:          pluginPattern,
+			ProtocolVersions: []int{int(sdk.HandshakeConfig.ProtocolVersion)},
+			RequireManifest:  true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		kvPlugin, err := registry.Lookup(pluginName)
+		if err != nil {
+			return nil, err
+		}
+		if err := kvPlugin.Verify(); err != nil {
+			return nil, err
+		}
+		pluginCmd = kvPlugin.Cmd
+		secureConfig = kvPlugin.SecureConfig
+	}
+
+	// The plugin is started by a supervisor, which restarts the plugin should
+	// it crash, and retries the calls that are safe to repeat.
+	//
+	// For each start of the plugin, a new plugin client is configured:
+	// - HandshakeConfig: is required
+	// - Plugins: is a map containing the supported plugins and their plugin.Plugin implementations
+	// - Cmd: points to the compiled binary of your plugin
+	// - SecureConfig: verifies the binary checksum again, just before it is launched
+	// - AllowedProtocols: by default only net/rpc is allowed, so add gRPC support
+	return supervisor.New(supervisor.Config{
+		ClientConfig: func() *plugin.ClientConfig {
+			return &plugin.ClientConfig{
+				HandshakeConfig:  sdk.HandshakeConfig,
+				Plugins:          pluginMap,
+				Cmd:              pluginCmd(),
+				SecureConfig:     secureConfig(),
+				AllowedProtocols: []plugin.Protocol{plugin.ProtocolNetRPC, plugin.ProtocolGRPC},
+				Logger:           logger(),
+			}
+		},
+		Dispense: dispense,
+		Logger:   logger(),
+	})
+}
+
+// dispense requests the KVStore from a newly started plugin client. The
+// plugin type matching the protocol the plugin is using is requested.
+func dispense(pluginClient *plugin.Client) (interface{}, error) {
+	client, err := pluginClient.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	pluginName := sdk.KVStoreGrpcPluginName
+	if pluginClient.Protocol() == plugin.ProtocolNetRPC {
+		pluginName = sdk.KVStoreNetRpcPluginName
+	}
+	return client.Dispense(pluginName)
+}
+
+// exitWithError prints the error returned by the plugin, and exits with the
+// code matching the kind of error.
+func exitWithError(err error) {
+	fmt.Println("Error:", err.Error())
+
+	switch {
+	case errors.Is(err, sdk.ErrNotFound):
+		os.Exit(exitNotFound)
+	case errors.Is(err, sdk.ErrInvalidKey):
+		os.Exit(exitInvalidKey)
+	case errors.Is(err, sdk.ErrPermissionDenied):
+		os.Exit(exitPermissionDenied)
+	case errors.Is(err, sdk.ErrPartiallyApplied):
+		os.Exit(exitPartiallyApplied)
+	default:
+		os.Exit(exitError)
 	}
 }
 
 // Contains all the data required to run the application.
 type cliArgs struct {
-	plugin  string // the plugin to be used
-	command string // get or put command
-	key     string // custom key name (appended to the KV store filename)
-	value   string // comment to be saved in the file
+	pluginName   string        // the plugin to be used
+	pluginNames  []string      // the plugins to run the command on at once
+	replicaNames []string      // the plugins to replicate the KV store across
+	readQuorum   int           // replicas that must respond to a read
+	writeQuorum  int           // replicas that must accept a write
+	pluginDirs   string        // directories to search for plugins
+	timeout      time.Duration // how long to wait for the plugin to respond
+	ttl          time.Duration // how long a put key lives before it expires, zero for never
+	listenAddr   string        // address the serve command listens on
+	command      string        // get, put, list, delete, has, import, put-file, get-file, history, get-revision, rollback, txn, watch, serve, or repl command
+	key          string        // custom key name (appended to the KV store filename), list prefix, or import file
+	value        string        // comment to be saved in the file, or the path of a put-file/get-file file
+	revision     int64         // the revision of the get-revision and rollback commands
+	txn          *sdk.Txn      // the compares and ops of the txn command
 }
 
 func parseFlags() cliArgs {
 	grpc := flag.Bool("grpc", false, "App will use plugin-go-grpc.")
 	rpc := flag.Bool("rpc", false, "App will use plugin-go-netrpc.")
 	python := flag.Bool("python", false, "App will use plugin-python.")
+	name := flag.String("plugin-name", "", "Name of a discovered plugin to use, e.g. go-grpc.")
+	plugins := flag.String("plugins", "", "Plugins to run the command on at once, separated by commas, e.g. grpc,rpc,python.")
+	replicas := flag.String("replicas", "", "Plugins to replicate the KV store across, separated by commas, e.g. grpc,python.")
+	readQuorum := flag.Int("read-quorum", 0, "Replicas that must respond to a read, defaults to a majority.")
+	writeQuorum := flag.Int("write-quorum", 0, "Replicas that must accept a write, defaults to all.")
+	dirs := flag.String("plugin-dir", ".", "Directories to search for plugins, separated by the OS path list separator.")
+	timeout := flag.Duration("timeout", 5*time.Second, "How long to wait for the plugin to respond.")
+	ttl := flag.Duration("ttl", 0, "How long a put key lives before it expires, e.g. 30s. Keys never expire by default.")
+	listenAddr := flag.String("listen", defaultListenAddr, "Address for the serve command: unix:<path> for a Unix socket, or <host>:<port> for TCP.")
 	flag.Parse()
 
-	var pluginType string
-	if *grpc {
-		pluginType = "grpc"
+	// The --grpc and --rpc flags are shortcuts for the discovered Go plugins.
+	var pluginName string
+	var pluginNames, replicaNames []string
+	if len(*plugins) > 0 {
+		pluginNames = splitPluginNames(*plugins)
+	} else if len(*replicas) > 0 {
+		replicaNames = splitPluginNames(*replicas)
+	} else if len(*name) > 0 {
+		pluginName = resolvePluginName(*name)
+	} else if *grpc {
+		pluginName = pluginAliases["grpc"]
 	} else if *rpc {
-		pluginType = "rpc"
+		pluginName = pluginAliases["rpc"]
 	} else if *python {
-		pluginType = "python"
+		pluginName = pythonPluginName
 	} else {
-		fmt.Println("a plugin type must be specified, include: --grpc, --rpc, or --python")
+		fmt.Println("a plugin must be specified, include: --grpc, --rpc, --python, or --plugin-name")
 		os.Exit(1)
 	}
 
 	command := flag.Arg(0)
-	if command != "get" && command != "put" {
-		fmt.Printf("invalid command, must be 'get' or 'put', given '%s'\n", command)
+	switch command {
+	case "get", "put", "list", "delete", "has", "import", "put-file", "get-file", "history", "get-revision", "rollback", "txn", "watch", "serve", "repl":
+	default:
+		fmt.Printf("invalid command, must be 'get', 'put', 'list', 'delete', 'has', 'import', 'put-file', 'get-file', 'history', 'get-revision', 'rollback', 'txn', 'watch', 'serve', or 'repl', given '%s'\n", command)
+		os.Exit(1)
+	}
+
+	singlePlugin := command == "put-file" || command == "get-file" || command == "history" || command == "get-revision" ||
+		command == "rollback" || command == "txn" || command == "watch" || command == "serve" || command == "repl"
+	if (len(pluginNames) > 0 || len(replicaNames) > 0) && singlePlugin {
+		fmt.Printf("the '%s' command can only use a single plugin\n", command)
 		os.Exit(1)
 	}
 
+	if *ttl != 0 && ((command != "put" && command != "repl") || len(replicaNames) > 0) {
+		fmt.Println("the --ttl flag can only be used with the 'put' and 'repl' commands, and not with replicas")
+		os.Exit(1)
+	} else if *ttl < 0 {
+		fmt.Println("the --ttl flag must not be negative")
+		os.Exit(1)
+	}
+
+	// The steps of a transaction are given as the arguments, rather than a
+	// key and value.
+	var txn *sdk.Txn
+	if command == "txn" {
+		var err error
+		if txn, err = parseTxn(flag.Args()[1:]); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
 	key := flag.Arg(1)
 	value := flag.Arg(2)
-	if len(key) == 0 {
+	if command == "import" && len(key) == 0 {
+		fmt.Println("a JSON or CSV file must be provided with the 'import' command")
+		os.Exit(1)
+	} else if len(key) == 0 && command != "list" && command != "txn" && command != "watch" && command != "serve" && command != "repl" {
 		fmt.Println("key must be present")
 		os.Exit(1)
 	} else if command == "put" && len(value) == 0 {
 		fmt.Println("value must be provide with the 'put' command")
 		os.Exit(1)
+	} else if (command == "put-file" || command == "get-file") && len(value) == 0 {
+		fmt.Printf("a file must be provided with the '%s' command\n", command)
+		os.Exit(1)
+	}
+
+	var revision int64
+	if command == "get-revision" || command == "rollback" {
+		var err error
+		if revision, err = parseRevision(value); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
 	}
 
 	return cliArgs{
-		plugin:  pluginType,
-		command: command,
-		key:     key,
-		value:   value,
+		pluginName:   pluginName,
+		pluginNames:  pluginNames,
+		replicaNames: replicaNames,
+		readQuorum:   *readQuorum,
+		writeQuorum:  *writeQuorum,
+		pluginDirs:   *dirs,
+		timeout:      *timeout,
+		ttl:          *ttl,
+		listenAddr:   *listenAddr,
+		command:      command,
+		key:          key,
+		value:        value,
+		revision:     revision,
+		txn:          txn,
 	}
 }
 
@@ -0,0 +1,518 @@
+// A plugin example of type KVStore, which stores the keys in an embedded
+// bbolt database, and communicates over either gRPC or net/rpc.
+//
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-plugin"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/storage"
+)
+
+// The database file of this plugin, kept in the data directory.
+const databaseFilename = "kv_bolt.db"
+
+// The bucket holding the keys and their values.
+var bucketName = []byte("kv")
+
+// The bucket holding the expiry time of the keys with a TTL, in Unix
+// nanoseconds.
+var expiresBucketName = []byte("expires")
+
+// The bucket holding a bucket for each key, with its revisions. Each revision
+// is keyed by its number, and holds the time it was put in Unix nanoseconds,
+// followed by the value.
+var revisionsBucketName = []byte("revisions")
+
+// How often the expired keys are removed.
+const sweepInterval = time.Minute
+
+// protocolEnv is the environment variable choosing the protocol the plugin
+// serves: "grpc" (the default), or "netrpc".
+const protocolEnv = "KV_PLUGIN_PROTOCOL"
+
+// BoltPlugin is a KVStore storing every key in a single database file. Each
+// Put, and each PutMany, is written in its own transaction, which is synced
+// to disk before the plugin responds, so a write that succeeded is never
+// lost, and a batch is either written in full or not at all. Keys put with a
+// TTL are hidden once expired.
+//
+// Each value put is also kept as a revision of the key, within the same
+// transaction, up to the number of revisions.
+type BoltPlugin struct {
+	db        *bolt.DB
+	revisions int
+}
+
+// openBoltPlugin opens the database in the data directory, creating it when
+// it does not exist. Only one process can open the database at a time, so
+// opening fails when it is still in use after the timeout.
+func openBoltPlugin(dir string, revisions int) (*BoltPlugin, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating the data directory: %w", err)
+	}
+
+	db, err := bolt.Open(filepath.Join(dir, databaseFilename), 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening the database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketName, expiresBucketName, revisionsBucketName} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating the buckets: %w", err)
+	}
+	return &BoltPlugin{db: db, revisions: revisions}, nil
+}
+
+// Put writes the value of the key, replacing any value stored. The key never
+// expires, even if it was put with a TTL before.
+func (p *BoltPlugin) Put(key string, value []byte) error {
+	return p.PutMany([]sdk.KeyValue{{Key: key, Value: value}})
+}
+
+// PutWithTTL writes the value of the key, along with the time it expires, in
+// a single transaction.
+func (p *BoltPlugin) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	if err := sdk.ValidateKey(key); err != nil {
+		return err
+	}
+
+	expires := make([]byte, 8)
+	binary.BigEndian.PutUint64(expires, uint64(time.Now().Add(ttl).UnixNano()))
+
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		if err := p.putValue(tx, []byte(key), value); err != nil {
+			return err
+		}
+		return tx.Bucket(expiresBucketName).Put([]byte(key), expires)
+	})
+	return databaseError(err)
+}
+
+// Get returns the value stored for the key.
+func (p *BoltPlugin) Get(key string) ([]byte, error) {
+	if err := sdk.ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	err := p.db.View(func(tx *bolt.Tx) error {
+		// The value is only valid during the transaction, so is copied.
+		v := tx.Bucket(bucketName).Get([]byte(key))
+		if v == nil || expired(tx, []byte(key), time.Now()) {
+			return sdk.NewError(sdk.NotFound, "key %q not found", key)
+		}
+		value = append([]byte{}, v...)
+		return nil
+	})
+	return value, databaseError(err)
+}
+
+// List returns the keys starting with the prefix, in sorted order, leaving out
+// the expired keys.
+func (p *BoltPlugin) List(prefix string) ([]string, error) {
+	var keys []string
+	err := p.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		c := tx.Bucket(bucketName).Cursor()
+		for k, _ := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = c.Next() {
+			if !expired(tx, k, now) {
+				keys = append(keys, string(k))
+			}
+		}
+		return nil
+	})
+	return keys, databaseError(err)
+}
+
+// Delete removes the key, along with its expiry time and revisions, if it
+// exists.
+func (p *BoltPlugin) Delete(key string) error {
+	if err := sdk.ValidateKey(key); err != nil {
+		return err
+	}
+
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		return deleteKey(tx, []byte(key))
+	})
+	return databaseError(err)
+}
+
+// Has reports whether the key exists, and has not expired.
+func (p *BoltPlugin) Has(key string) (bool, error) {
+	if err := sdk.ValidateKey(key); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err := p.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(bucketName).Get([]byte(key)) != nil && !expired(tx, []byte(key), time.Now())
+		return nil
+	})
+	return exists, databaseError(err)
+}
+
+// PutMany writes every item in a single transaction, so either all the items
+// are written, or none of them are.
+func (p *BoltPlugin) PutMany(items []sdk.KeyValue) error {
+	for _, item := range items {
+		if err := sdk.ValidateKey(item.Key); err != nil {
+			return err
+		}
+	}
+
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		expires := tx.Bucket(expiresBucketName)
+		for _, item := range items {
+			if err := p.putValue(tx, []byte(item.Key), item.Value); err != nil {
+				return err
+			}
+			if err := expires.Delete([]byte(item.Key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return databaseError(err)
+}
+
+// GetMany reads the keys in a single transaction, so the values are all
+// read from the same snapshot of the database.
+func (p *BoltPlugin) GetMany(keys []string) ([]sdk.KeyValue, error) {
+	for _, key := range keys {
+		if err := sdk.ValidateKey(key); err != nil {
+			return nil, err
+		}
+	}
+
+	var items []sdk.KeyValue
+	err := p.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		b := tx.Bucket(bucketName)
+		for _, key := range keys {
+			if v := b.Get([]byte(key)); v != nil && !expired(tx, []byte(key), now) {
+				items = append(items, sdk.KeyValue{Key: key, Value: append([]byte{}, v...)})
+			}
+		}
+		return nil
+	})
+	return items, databaseError(err)
+}
+
+// History returns the revisions kept of the key, oldest first.
+func (p *BoltPlugin) History(key string) ([]sdk.Revision, error) {
+	if err := sdk.ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	var revisions []sdk.Revision
+	err := p.db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(bucketName).Get([]byte(key)) == nil || expired(tx, []byte(key), time.Now()) {
+			return sdk.NewError(sdk.NotFound, "key %q not found", key)
+		}
+		revs := tx.Bucket(revisionsBucketName).Bucket([]byte(key))
+		if revs == nil {
+			return nil
+		}
+		return revs.ForEach(func(k, v []byte) error {
+			revisions = append(revisions, sdk.Revision{
+				Number: int64(binary.BigEndian.Uint64(k)),
+				Time:   time.Unix(0, int64(binary.BigEndian.Uint64(v))),
+				Size:   int64(len(v) - 8),
+			})
+			return nil
+		})
+	})
+	return revisions, databaseError(err)
+}
+
+// GetRevision returns the value of the key at the revision.
+func (p *BoltPlugin) GetRevision(key string, revision int64) ([]byte, error) {
+	if err := sdk.ValidateKey(key); err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	err := p.db.View(func(tx *bolt.Tx) error {
+		v, err := revisionValue(tx, key, revision)
+		value = v
+		return err
+	})
+	return value, databaseError(err)
+}
+
+// Rollback puts the value of the key at the revision again, as a new
+// revision, in a single transaction. The key never expires once rolled back.
+func (p *BoltPlugin) Rollback(key string, revision int64) error {
+	if err := sdk.ValidateKey(key); err != nil {
+		return err
+	}
+
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		value, err := revisionValue(tx, key, revision)
+		if err != nil {
+			return err
+		}
+		if err := p.putValue(tx, []byte(key), value); err != nil {
+			return err
+		}
+		return tx.Bucket(expiresBucketName).Delete([]byte(key))
+	})
+	return databaseError(err)
+}
+
+// Txn checks the compares and applies the ops in a single database
+// transaction, so either every op is applied or none are.
+func (p *BoltPlugin) Txn(txn sdk.Txn) (bool, error) {
+	for _, key := range txn.Keys() {
+		if err := sdk.ValidateKey(key); err != nil {
+			return false, err
+		}
+	}
+
+	var succeeded bool
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, c := range txn.Compares {
+			v := tx.Bucket(bucketName).Get([]byte(c.Key))
+			if v != nil && expired(tx, []byte(c.Key), now) {
+				v = nil
+			}
+			switch c.Type {
+			case sdk.CompareValue:
+				if v == nil || !bytes.Equal(v, c.Value) {
+					return nil
+				}
+			case sdk.CompareAbsent:
+				if v != nil {
+					return nil
+				}
+			}
+		}
+
+		expires := tx.Bucket(expiresBucketName)
+		for _, op := range txn.Ops {
+			key := []byte(op.Key)
+			switch op.Type {
+			case sdk.OpPut:
+				if err := p.putValue(tx, key, op.Value); err != nil {
+					return err
+				}
+				if err := expires.Delete(key); err != nil {
+					return err
+				}
+			case sdk.OpDelete:
+				if err := deleteKey(tx, key); err != nil {
+					return err
+				}
+			}
+		}
+		succeeded = true
+		return nil
+	})
+	return succeeded, databaseError(err)
+}
+
+// putValue writes the value of the key, keeping it as a new revision, then
+// removes the oldest revisions beyond the number kept.
+func (p *BoltPlugin) putValue(tx *bolt.Tx, key, value []byte) error {
+	if err := tx.Bucket(bucketName).Put(key, value); err != nil {
+		return err
+	}
+	if p.revisions == 0 {
+		return nil
+	}
+
+	revs, err := tx.Bucket(revisionsBucketName).CreateBucketIfNotExists(key)
+	if err != nil {
+		return err
+	}
+	number, err := revs.NextSequence()
+	if err != nil {
+		return err
+	}
+	rev := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(rev, uint64(time.Now().UnixNano()))
+	copy(rev[8:], value)
+	if err := revs.Put(revisionKey(int64(number)), rev); err != nil {
+		return err
+	}
+
+	// Keys can not be deleted while iterating with the cursor, so the oldest
+	// revisions are found first.
+	var numbers [][]byte
+	c := revs.Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		numbers = append(numbers, append([]byte{}, k...))
+	}
+	for len(numbers) > p.revisions {
+		if err := revs.Delete(numbers[0]); err != nil {
+			return err
+		}
+		numbers = numbers[1:]
+	}
+	return nil
+}
+
+// revisionValue returns a copy of the value of the key at the revision.
+func revisionValue(tx *bolt.Tx, key string, revision int64) ([]byte, error) {
+	if expired(tx, []byte(key), time.Now()) {
+		return nil, sdk.NewError(sdk.NotFound, "key %q not found", key)
+	}
+	var v []byte
+	if revs := tx.Bucket(revisionsBucketName).Bucket([]byte(key)); revs != nil && revision > 0 {
+		v = revs.Get(revisionKey(revision))
+	}
+	if v == nil {
+		return nil, sdk.NewError(sdk.NotFound, "revision %d of key %q not found", revision, key)
+	}
+	return append([]byte{}, v[8:]...), nil
+}
+
+// revisionKey returns the key of a revision, which sorts in number order.
+func revisionKey(number int64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(number))
+	return k
+}
+
+// deleteKey removes the key, along with its expiry time and revisions.
+func deleteKey(tx *bolt.Tx, key []byte) error {
+	if err := tx.Bucket(bucketName).Delete(key); err != nil {
+		return err
+	}
+	if err := tx.Bucket(expiresBucketName).Delete(key); err != nil {
+		return err
+	}
+	err := tx.Bucket(revisionsBucketName).DeleteBucket(key)
+	if err == bolt.ErrBucketNotFound {
+		return nil
+	}
+	return err
+}
+
+// Sweep removes the expired keys, returning how many were removed.
+func (p *BoltPlugin) Sweep() (int, error) {
+	var removed int
+	err := p.db.Update(func(tx *bolt.Tx) error {
+		// Keys can not be deleted while iterating with the cursor, so the
+		// expired keys are found first.
+		now := time.Now()
+		var keys [][]byte
+		c := tx.Bucket(expiresBucketName).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			if expired(tx, k, now) {
+				keys = append(keys, append([]byte{}, k...))
+			}
+		}
+		for _, key := range keys {
+			if err := deleteKey(tx, key); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	return removed, databaseError(err)
+}
+
+// SweepEvery calls Sweep at each interval, until the context is done, so the
+// expired keys do not build up in the database.
+func (p *BoltPlugin) SweepEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := p.Sweep(); err != nil {
+				fmt.Fprintln(os.Stderr, "sweeping expired keys:", err)
+			}
+		}
+	}
+}
+
+// expired reports whether the key has an expiry time which has passed.
+func expired(tx *bolt.Tx, key []byte, now time.Time) bool {
+	v := tx.Bucket(expiresBucketName).Get(key)
+	if len(v) != 8 {
+		return false
+	}
+	return !now.Before(time.Unix(0, int64(binary.BigEndian.Uint64(v))))
+}
+
+// databaseError returns the errors of the database as Internal errors, while
+// the Errors returned within a transaction are kept.
+func databaseError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*sdk.Error); ok {
+		return err
+	}
+	return sdk.NewError(sdk.Internal, "database: %s", err)
+}
+
+// go-plugin's are normal Go applications so require a main entry point.
+// Once the host application has loaded (dispensed) the plugin, go-plugin will
+// start the plugin, and manage its full lifecycle.
+func main() {
+	// The number of revisions kept of each key is set by the host
+	// application.
+	revisions, err := storage.Revisions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	kv, err := openBoltPlugin(storage.DataDir(), revisions)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer kv.db.Close()
+
+	// Expired keys are hidden, and removed in the background.
+	go kv.SweepEvery(context.Background(), sweepInterval)
+
+	// go-plugin serves every plugin in the set using the same protocol, so
+	// only the plugin for the chosen protocol is assigned.
+	config := &plugin.ServeConfig{HandshakeConfig: sdk.HandshakeConfig}
+	switch protocol := os.Getenv(protocolEnv); protocol {
+	case "", "grpc":
+		config.Plugins = plugin.PluginSet{sdk.KVStoreGrpcPluginName: &sdk.KVPluginGRPC{Impl: kv}}
+
+		// A non-nil value here enables gRPC serving for this plugin.
+		config.GRPCServer = plugin.DefaultGRPCServer
+	case "netrpc":
+		config.Plugins = plugin.PluginSet{sdk.KVStoreNetRpcPluginName: &sdk.KVPluginRPC{Impl: kv}}
+	default:
+		fmt.Fprintf(os.Stderr, "%s must be 'grpc' or 'netrpc', given '%s'\n", protocolEnv, protocol)
+		os.Exit(1)
+	}
+
+	// start listening for incoming requests.
+	plugin.Serve(config)
+}
@@ -7,40 +7,263 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/go-plugin"
 
 	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/storage"
 )
 
 // the files for this plugin use the prefix:
 const filenamePrefix = "kv_grpc_"
 
+// How often the files of expired keys are removed.
+const sweepInterval = time.Minute
+
 // GrpcPlugin is our custom plugin: it's a real implementation of the KVStore
-// plugin type that writes to a local file with the key name and the contents
-// are the value of the key.
-type GrpcPlugin struct{}
+// plugin type that writes to a file in the data directory with the key name
+// and the contents are the value of the key.
+type GrpcPlugin struct {
+	store *storage.FileStore
+
+	// txnMu serializes the transactions, so the compares of a transaction
+	// still hold when its ops are applied.
+	txnMu *sync.Mutex
+}
 
 // Put will overwrite the file contents with the new key/value data.
-func (GrpcPlugin) Put(key string, value []byte) error {
+func (p GrpcPlugin) Put(key string, value []byte) error {
+	value = []byte(fmt.Sprintf("%s\n\nWritten from plugin-go-grpc", string(value)))
+	return p.store.WriteFile(key, value)
+}
+
+// PutWithTTL writes the file as for Put, with the key expiring once the ttl has
+// passed.
+func (p GrpcPlugin) PutWithTTL(key string, value []byte, ttl time.Duration) error {
 	value = []byte(fmt.Sprintf("%s\n\nWritten from plugin-go-grpc", string(value)))
-	return os.WriteFile(filenamePrefix+key, value, 0644)
+	return p.store.WriteFileWithExpiry(key, value, time.Now().Add(ttl))
 }
 
 // Get reads the file and returns the value stored for the matching key.
-func (GrpcPlugin) Get(key string) ([]byte, error) {
-	return os.ReadFile(filenamePrefix + key)
+func (p GrpcPlugin) Get(key string) ([]byte, error) {
+	return p.store.ReadFile(key)
+}
+
+// List returns the keys of all files in the data directory that start with
+// the plugin filename prefix, followed by the key prefix.
+func (p GrpcPlugin) List(prefix string) ([]string, error) {
+	return p.store.Keys(prefix)
+}
+
+// Delete removes the file for the matching key, if it exists.
+func (p GrpcPlugin) Delete(key string) error {
+	return p.store.Remove(key)
+}
+
+// Has reports whether a file exists for the matching key.
+func (p GrpcPlugin) Has(key string) (bool, error) {
+	return p.store.Exists(key)
+}
+
+// History returns the revisions kept of the file for the key.
+func (p GrpcPlugin) History(key string) ([]sdk.Revision, error) {
+	return p.store.History(key)
+}
+
+// GetRevision reads the file for the key at the revision.
+func (p GrpcPlugin) GetRevision(key string, revision int64) ([]byte, error) {
+	return p.store.ReadRevision(key, revision)
+}
+
+// Rollback writes the file for the key at the revision again, as a new
+// revision.
+func (p GrpcPlugin) Rollback(key string, revision int64) error {
+	return p.store.Rollback(key, revision)
+}
+
+// PutMany checks every key before writing any of the files, so a batch with
+// an invalid key is refused as a whole.
+func (p GrpcPlugin) PutMany(items []sdk.KeyValue) error {
+	for _, item := range items {
+		if err := sdk.ValidateKey(item.Key); err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		if err := p.Put(item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMany reads the files of the keys, leaving out the keys not found.
+func (p GrpcPlugin) GetMany(keys []string) ([]sdk.KeyValue, error) {
+	var items []sdk.KeyValue
+	for _, key := range keys {
+		value, err := p.Get(key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		items = append(items, sdk.KeyValue{Key: key, Value: value})
+	}
+	return items, nil
+}
+
+// PutStream copies the value to a pending file, which only replaces the file
+// of the key once the whole value has been received.
+func (p GrpcPlugin) PutStream(key string, r io.Reader) error {
+	f, err := p.store.Create(key)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, "\n\nWritten from plugin-go-grpc"); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// GetStream copies the file of the key to w.
+func (p GrpcPlugin) GetStream(key string, w io.Writer) error {
+	f, err := p.store.Open(key)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Txn checks the compares against the files of the keys, then applies the ops
+// in order. Should an op fail, the files of the keys changed by the earlier ops
+// are restored from a snapshot, along with their expiry times and revisions, so
+// either every op is applied or none are.
+//
+// Transactions are serialized by the plugin, but the other writes are not,
+// e.g. a Put, or a process sharing the data directory, may change a key while
+// a transaction is being applied.
+func (p GrpcPlugin) Txn(txn sdk.Txn) (bool, error) {
+	for _, key := range txn.Keys() {
+		if err := sdk.ValidateKey(key); err != nil {
+			return false, err
+		}
+	}
+
+	p.txnMu.Lock()
+	defer p.txnMu.Unlock()
+
+	for _, c := range txn.Compares {
+		ok, err := p.compare(c)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	// The files of each key are kept as they were before its first op, so
+	// the ops applied can be undone should a later op fail.
+	snapshots := make(map[string]*storage.Snapshot)
+	for _, op := range txn.Ops {
+		if _, ok := snapshots[op.Key]; !ok {
+			snap, err := p.store.Snapshot(op.Key)
+			if err != nil {
+				return false, undo(snapshots, err)
+			}
+			snapshots[op.Key] = snap
+		}
+		if err := p.apply(op); err != nil {
+			return false, undo(snapshots, err)
+		}
+	}
+	for _, snap := range snapshots {
+		snap.Discard()
+	}
+	return true, nil
+}
+
+// compare reports whether the compare holds for the file of the key.
+func (p GrpcPlugin) compare(c sdk.Compare) (bool, error) {
+	if c.Type == sdk.CompareAbsent {
+		exists, err := p.store.Exists(c.Key)
+		return !exists, err
+	}
+
+	value, err := p.store.ReadFile(c.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return bytes.Equal(value, c.Value), nil
+}
+
+// apply makes the change of the op to the file of the key.
+func (p GrpcPlugin) apply(op sdk.Op) error {
+	if op.Type == sdk.OpDelete {
+		return p.Delete(op.Key)
+	}
+	return p.Put(op.Key, op.Value)
+}
+
+// undo restores the keys changed by a transaction that failed with err. When
+// a key can not be restored, some of the ops may remain applied, so a
+// PartiallyApplied error is returned in place of err.
+func undo(snapshots map[string]*storage.Snapshot, err error) error {
+	var failed []string
+	for _, snap := range snapshots {
+		if restoreErr := snap.Restore(); restoreErr != nil {
+			failed = append(failed, restoreErr.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return sdk.NewError(sdk.PartiallyApplied, "%s; the transaction may be partially applied, as restoring the keys failed: %s", err, strings.Join(failed, "; "))
+	}
+	return err
 }
 
 // go-plugin's are normal Go applications so require a main entry point.
 // Once the host application has loaded (dispensed) the plugin, go-plugin will
 // start the plugin, and manage its full lifecycle.
 func main() {
+	// The files are kept in the data directory given by the host application.
+	store, err := storage.NewFileStore(storage.DataDir(), filenamePrefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// The number of revisions kept of each key is set by the host
+	// application.
+	revisions, err := storage.Revisions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	store.SetRevisions(revisions)
+
+	// Expired keys are hidden by the store, with their files being removed
+	// in the background.
+	go store.SweepEvery(context.Background(), sweepInterval)
+
 	// Assign our plugin as the required plugin type.
 	plugins := plugin.PluginSet{
-		sdk.KVStoreGrpcPluginName: &sdk.KVPluginGRPC{Impl: &GrpcPlugin{}},
+		sdk.KVStoreGrpcPluginName: &sdk.KVPluginGRPC{Impl: &GrpcPlugin{store: store, txnMu: &sync.Mutex{}}},
 	}
 
 	// start listening for incoming gRPC requests.
@@ -0,0 +1,100 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/storage"
+)
+
+// A single write to a file can notify several changes, e.g. creating then
+// writing it, so the changes made within this period are sent as one event.
+const watchDebounce = 50 * time.Millisecond
+
+// Watch sends an event for each key file starting with the prefix that is
+// written or removed in the data directory, whether by this plugin or any
+// other process.
+func (p GrpcPlugin) Watch(ctx context.Context, prefix string) (<-chan sdk.WatchEvent, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, sdk.NewError(sdk.Internal, "watching keys: %s", err)
+	}
+	if err := watcher.Add(p.store.Dir()); err != nil {
+		watcher.Close()
+		return nil, sdk.NewError(sdk.Internal, "watching keys: %s", err)
+	}
+
+	events := make(chan sdk.WatchEvent)
+	go watchFiles(ctx, p.store, watcher, prefix, events)
+	return events, nil
+}
+
+// watchFiles sends the changes to the key files, until the context is done.
+func watchFiles(ctx context.Context, store *storage.FileStore, watcher *fsnotify.Watcher, prefix string, events chan<- sdk.WatchEvent) {
+	defer close(events)
+	defer watcher.Close()
+
+	send := func(event sdk.WatchEvent) bool {
+		select {
+		case events <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// The keys changed since the last events were sent, in order of change.
+	var changed []string
+	pending := make(map[string]bool)
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err, ok := <-watcher.Errors:
+			if ok {
+				send(sdk.WatchEvent{Err: sdk.NewError(sdk.Internal, "watching keys: %s", err)})
+			}
+			return
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			key, ok := store.Key(filepath.Base(e.Name))
+			if e.Op == fsnotify.Chmod || !ok || !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			if !pending[key] {
+				pending[key] = true
+				changed = append(changed, key)
+			}
+			if flush == nil {
+				flush = time.After(watchDebounce)
+			}
+		case <-flush:
+			// Whether the key was put or deleted is given by the file
+			// existing once the changes have settled.
+			for _, key := range changed {
+				event := sdk.WatchEvent{Type: sdk.EventPut, Key: key}
+				if exists, err := store.Exists(key); err == nil && !exists {
+					event.Type = sdk.EventDelete
+				}
+				if !send(event) {
+					return
+				}
+			}
+			changed = nil
+			pending = make(map[string]bool)
+			flush = nil
+		}
+	}
+}
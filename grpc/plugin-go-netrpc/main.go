@@ -6,40 +6,139 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/hashicorp/go-plugin"
 
 	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/storage"
 )
 
 // the files for this plugin use the prefix:
 const filenamePrefix = "kv_rpc_"
 
-// NetRpcPlugin is our custom plugin: it's a real implementation of the KV
-// plugin type that writes to a local file with the key name and the contents
-// are the value of the key.
-type NetRpcPlugin struct{}
+// How often the files of expired keys are removed.
+const sweepInterval = time.Minute
+
+// NetRpcPlugin is our custom plugin: it's a real implementation of the KVStore
+// plugin type that writes to a file in the data directory with the key name
+// and the contents are the value of the key.
+type NetRpcPlugin struct {
+	store *storage.FileStore
+}
 
 // Put will overwrite the file contents with the new key/value data.
-func (NetRpcPlugin) Put(key string, value []byte) error {
+func (p NetRpcPlugin) Put(key string, value []byte) error {
+	value = []byte(fmt.Sprintf("%s\n\nWritten from plugin-go-netrpc", string(value)))
+	return p.store.WriteFile(key, value)
+}
+
+// PutWithTTL writes the file as for Put, with the key expiring once the ttl has
+// passed.
+func (p NetRpcPlugin) PutWithTTL(key string, value []byte, ttl time.Duration) error {
 	value = []byte(fmt.Sprintf("%s\n\nWritten from plugin-go-netrpc", string(value)))
-	return os.WriteFile(filenamePrefix+key, value, 0644)
+	return p.store.WriteFileWithExpiry(key, value, time.Now().Add(ttl))
 }
 
 // Get reads the file and returns the value stored for the matching key.
-func (NetRpcPlugin) Get(key string) ([]byte, error) {
-	return os.ReadFile(filenamePrefix + key)
+func (p NetRpcPlugin) Get(key string) ([]byte, error) {
+	return p.store.ReadFile(key)
+}
+
+// List returns the keys of all files in the data directory that start with
+// the plugin filename prefix, followed by the key prefix.
+func (p NetRpcPlugin) List(prefix string) ([]string, error) {
+	return p.store.Keys(prefix)
+}
+
+// Delete removes the file for the matching key, if it exists.
+func (p NetRpcPlugin) Delete(key string) error {
+	return p.store.Remove(key)
+}
+
+// Has reports whether a file exists for the matching key.
+func (p NetRpcPlugin) Has(key string) (bool, error) {
+	return p.store.Exists(key)
+}
+
+// History returns the revisions kept of the file for the key.
+func (p NetRpcPlugin) History(key string) ([]sdk.Revision, error) {
+	return p.store.History(key)
+}
+
+// GetRevision reads the file for the key at the revision.
+func (p NetRpcPlugin) GetRevision(key string, revision int64) ([]byte, error) {
+	return p.store.ReadRevision(key, revision)
+}
+
+// Rollback writes the file for the key at the revision again, as a new
+// revision.
+func (p NetRpcPlugin) Rollback(key string, revision int64) error {
+	return p.store.Rollback(key, revision)
+}
+
+// PutMany checks every key before writing any of the files, so a batch with
+// an invalid key is refused as a whole.
+func (p NetRpcPlugin) PutMany(items []sdk.KeyValue) error {
+	for _, item := range items {
+		if err := sdk.ValidateKey(item.Key); err != nil {
+			return err
+		}
+	}
+	for _, item := range items {
+		if err := p.Put(item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMany reads the files of the keys, leaving out the keys not found.
+func (p NetRpcPlugin) GetMany(keys []string) ([]sdk.KeyValue, error) {
+	var items []sdk.KeyValue
+	for _, key := range keys {
+		value, err := p.Get(key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		items = append(items, sdk.KeyValue{Key: key, Value: value})
+	}
+	return items, nil
 }
 
 // go-plugin's are normal Go applications so require a main entry point.
 // Once the host application has loaded (dispensed) the plugin, go-plugin will
 // start the plugin, and manage its full lifecycle.
 func main() {
+	// The files are kept in the data directory given by the host application.
+	store, err := storage.NewFileStore(storage.DataDir(), filenamePrefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// The number of revisions kept of each key is set by the host
+	// application.
+	revisions, err := storage.Revisions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	store.SetRevisions(revisions)
+
+	// Expired keys are hidden by the store, with their files being removed
+	// in the background.
+	go store.SweepEvery(context.Background(), sweepInterval)
+
 	// Assign our plugin as the required plugin type.
 	plugins := plugin.PluginSet{
-		sdk.KVStoreNetRpcPluginName: &sdk.KVPluginRPC{Impl: &NetRpcPlugin{}},
+		sdk.KVStoreNetRpcPluginName: &sdk.KVPluginRPC{Impl: &NetRpcPlugin{store: store}},
 	}
 
 	// start listening for incoming net/rpc requests.
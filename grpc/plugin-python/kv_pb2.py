@@ -16,7 +16,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x08kv.proto\x12\x05proto\"\x19\n\nGetRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\"\x1c\n\x0bGetResponse\x12\r\n\x05value\x18\x01 \x01(\x0c\"(\n\nPutRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x0c\"\x07\n\x05\x45mpty2Z\n\x02KV\x12,\n\x03Get\x12\x11.proto.GetRequest\x1a\x12.proto.GetResponse\x12&\n\x03Put\x12\x11.proto.PutRequest\x1a\x0c.proto.EmptyB1Z/github.com/mrcook/go-plugin-examples/grpc/protob\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\x08kv.proto\x12\x05proto\"\x19\n\nGetRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\"\x1c\n\x0bGetResponse\x12\r\n\x05value\x18\x01 \x01(\x0c\"<\n\nPutRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x0c\x12\x12\n\nttl_millis\x18\x03 \x01(\x03\"\x1d\n\x0bListRequest\x12\x0e\n\x06prefix\x18\x01 \x01(\t\"\x1b\n\x0cListResponse\x12\x0b\n\x03key\x18\x01 \x01(\t\"\x1c\n\rDeleteRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\"\x19\n\nHasRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\"\x1d\n\x0bHasResponse\x12\x0e\n\x06\x65xists\x18\x01 \x01(\x08\"\x1e\n\x0cWatchRequest\x12\x0e\n\x06prefix\x18\x01 \x01(\t\">\n\nWatchEvent\x12#\n\x04type\x18\x01 \x01(\x0e\x32\x15.proto.WatchEventType\x12\x0b\n\x03key\x18\x02 \x01(\t\"&\n\x08KeyValue\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05value\x18\x02 \x01(\x0c\"0\n\x0ePutManyRequest\x12\x1e\n\x05items\x18\x01 \x03(\x0b\x32\x0f.proto.KeyValue\"\x1e\n\x0eGetManyRequest\x12\x0c\n\x04keys\x18\x01 \x03(\t\"1\n\x0fGetManyResponse\x12\x1e\n\x05items\x18\x01 \x03(\x0b\x32\x0f.proto.KeyValue\".\n\x10PutStreamRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\r\n\x05\x63hunk\x18\x02 \x01(\x0c\"\"\n\x11GetStreamResponse\x12\r\n\x05\x63hunk\x18\x01 \x01(\x0c\"6\n\x08Revision\x12\x0e\n\x06number\x18\x01 \x01(\x03\x12\x0c\n\x04time\x18\x02 \x01(\x03\x12\x0c\n\x04size\x18\x03 \x01(\x03\"\x1d\n\x0eHistoryRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\"5\n\x0fHistoryResponse\x12\"\n\trevisions\x18\x01 \x03(\x0b\x32\x0f.proto.Revision\"0\n\x0fRevisionRequest\x12\x0b\n\x03key\x18\x01 \x01(\t\x12\x10\n\x08revision\x18\x02 \x01(\x03\"G\n\x07\x43ompare\x12 \n\x04type\x18\x01 \x01(\x0e\x32\x12.proto.CompareType\x12\x0b\n\x03key\x18\x02 \x01(\t\x12\r\n\x05value\x18\x03 \x01(\x0c\"=\n\x02Op\x12\x1b\n\x04type\x18\x01 \x01(\x0e\x32\r.proto.OpType\x12\x0b\n\x03key\x18\x02 \x01(\t\x12\r\n\x05value\x18\x03 \x01(\x0c\"F\n\nTxnRequest\x12 \n\x08\x63ompares\x18\x01 \x03(\x0b\x32\x0e.proto.Compare\x12\x16\n\x03ops\x18\x02 \x03(\x0b\x32\t.proto.Op\" \n\x0bTxnResponse\x12\x11\n\tsucceeded\x18\x01 \x01(\x08\"\x07\n\x05\x45mpty*%\n\x0eWatchEventType\x12\x07\n\x03PUT\x10\x00\x12\n\n\x06\x44\x45LETE\x10\x01*4\n\x0b\x43ompareType\x12\x11\n\rCOMPARE_VALUE\x10\x00\x12\x12\n\x0e\x43OMPARE_ABSENT\x10\x01*#\n\x06OpType\x12\n\n\x06OP_PUT\x10\x00\x12\r\n\tOP_DELETE\x10\x01\x32\x85\x06\n\x02KV\x12,\n\x03Get\x12\x11.proto.GetRequest\x1a\x12.proto.GetResponse\x12&\n\x03Put\x12\x11.proto.PutRequest\x1a\x0c.proto.Empty\x12\x31\n\x04List\x12\x12.proto.ListRequest\x1a\x13.proto.ListResponse0\x01\x12,\n\x06\x44\x65lete\x12\x14.proto.DeleteRequest\x1a\x0c.proto.Empty\x12,\n\x03Has\x12\x11.proto.HasRequest\x1a\x12.proto.HasResponse\x12\x31\n\x05Watch\x12\x13.proto.WatchRequest\x1a\x11.proto.WatchEvent0\x01\x12.\n\x07PutMany\x12\x15.proto.PutManyRequest\x1a\x0c.proto.Empty\x12\x38\n\x07GetMany\x12\x15.proto.GetManyRequest\x1a\x16.proto.GetManyResponse\x12\x36\n\rPutManyStream\x12\x15.proto.PutManyRequest\x1a\x0c.proto.Empty(\x01\x12\x34\n\tPutStream\x12\x17.proto.PutStreamRequest\x1a\x0c.proto.Empty(\x01\x12:\n\tGetStream\x12\x11.proto.GetRequest\x1a\x18.proto.GetStreamResponse0\x01\x12\x38\n\x07History\x12\x15.proto.HistoryRequest\x1a\x16.proto.HistoryResponse\x12\x39\n\x0bGetRevision\x12\x16.proto.RevisionRequest\x1a\x12.proto.GetResponse\x12\x30\n\x08Rollback\x12\x16.proto.RevisionRequest\x1a\x0c.proto.Empty\x12,\n\x03Txn\x12\x11.proto.TxnRequest\x1a\x12.proto.TxnResponseB1Z/github.com/mrcook/go-plugin-examples/grpc/protob\x06proto3')
 
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, globals())
 _builder.BuildTopDescriptorsAndMessages(DESCRIPTOR, 'kv_pb2', globals())
@@ -24,14 +24,62 @@ if _descriptor._USE_C_DESCRIPTORS == False:
 
   DESCRIPTOR._options = None
   DESCRIPTOR._serialized_options = b'Z/github.com/mrcook/go-plugin-examples/grpc/proto'
+  _WATCHEVENTTYPE._serialized_start=1082
+  _WATCHEVENTTYPE._serialized_end=1119
+  _COMPARETYPE._serialized_start=1121
+  _COMPARETYPE._serialized_end=1173
+  _OPTYPE._serialized_start=1175
+  _OPTYPE._serialized_end=1210
   _GETREQUEST._serialized_start=19
   _GETREQUEST._serialized_end=44
   _GETRESPONSE._serialized_start=46
   _GETRESPONSE._serialized_end=74
   _PUTREQUEST._serialized_start=76
-  _PUTREQUEST._serialized_end=116
-  _EMPTY._serialized_start=118
-  _EMPTY._serialized_end=125
-  _KV._serialized_start=127
-  _KV._serialized_end=217
+  _PUTREQUEST._serialized_end=136
+  _LISTREQUEST._serialized_start=138
+  _LISTREQUEST._serialized_end=167
+  _LISTRESPONSE._serialized_start=169
+  _LISTRESPONSE._serialized_end=196
+  _DELETEREQUEST._serialized_start=198
+  _DELETEREQUEST._serialized_end=226
+  _HASREQUEST._serialized_start=228
+  _HASREQUEST._serialized_end=253
+  _HASRESPONSE._serialized_start=255
+  _HASRESPONSE._serialized_end=284
+  _WATCHREQUEST._serialized_start=286
+  _WATCHREQUEST._serialized_end=316
+  _WATCHEVENT._serialized_start=318
+  _WATCHEVENT._serialized_end=380
+  _KEYVALUE._serialized_start=382
+  _KEYVALUE._serialized_end=420
+  _PUTMANYREQUEST._serialized_start=422
+  _PUTMANYREQUEST._serialized_end=470
+  _GETMANYREQUEST._serialized_start=472
+  _GETMANYREQUEST._serialized_end=502
+  _GETMANYRESPONSE._serialized_start=504
+  _GETMANYRESPONSE._serialized_end=553
+  _PUTSTREAMREQUEST._serialized_start=555
+  _PUTSTREAMREQUEST._serialized_end=601
+  _GETSTREAMRESPONSE._serialized_start=603
+  _GETSTREAMRESPONSE._serialized_end=637
+  _REVISION._serialized_start=639
+  _REVISION._serialized_end=693
+  _HISTORYREQUEST._serialized_start=695
+  _HISTORYREQUEST._serialized_end=724
+  _HISTORYRESPONSE._serialized_start=726
+  _HISTORYRESPONSE._serialized_end=779
+  _REVISIONREQUEST._serialized_start=781
+  _REVISIONREQUEST._serialized_end=829
+  _COMPARE._serialized_start=831
+  _COMPARE._serialized_end=902
+  _OP._serialized_start=904
+  _OP._serialized_end=965
+  _TXNREQUEST._serialized_start=967
+  _TXNREQUEST._serialized_end=1037
+  _TXNRESPONSE._serialized_start=1039
+  _TXNRESPONSE._serialized_end=1071
+  _EMPTY._serialized_start=1073
+  _EMPTY._serialized_end=1080
+  _KV._serialized_start=1213
+  _KV._serialized_end=1986
 # @@protoc_insertion_point(module_scope)
@@ -27,6 +27,71 @@ class KVStub(object):
                 request_serializer=kv__pb2.PutRequest.SerializeToString,
                 response_deserializer=kv__pb2.Empty.FromString,
                 )
+        self.List = channel.unary_stream(
+                '/proto.KV/List',
+                request_serializer=kv__pb2.ListRequest.SerializeToString,
+                response_deserializer=kv__pb2.ListResponse.FromString,
+                )
+        self.Delete = channel.unary_unary(
+                '/proto.KV/Delete',
+                request_serializer=kv__pb2.DeleteRequest.SerializeToString,
+                response_deserializer=kv__pb2.Empty.FromString,
+                )
+        self.Has = channel.unary_unary(
+                '/proto.KV/Has',
+                request_serializer=kv__pb2.HasRequest.SerializeToString,
+                response_deserializer=kv__pb2.HasResponse.FromString,
+                )
+        self.Watch = channel.unary_stream(
+                '/proto.KV/Watch',
+                request_serializer=kv__pb2.WatchRequest.SerializeToString,
+                response_deserializer=kv__pb2.WatchEvent.FromString,
+                )
+        self.PutMany = channel.unary_unary(
+                '/proto.KV/PutMany',
+                request_serializer=kv__pb2.PutManyRequest.SerializeToString,
+                response_deserializer=kv__pb2.Empty.FromString,
+                )
+        self.GetMany = channel.unary_unary(
+                '/proto.KV/GetMany',
+                request_serializer=kv__pb2.GetManyRequest.SerializeToString,
+                response_deserializer=kv__pb2.GetManyResponse.FromString,
+                )
+        self.PutManyStream = channel.stream_unary(
+                '/proto.KV/PutManyStream',
+                request_serializer=kv__pb2.PutManyRequest.SerializeToString,
+                response_deserializer=kv__pb2.Empty.FromString,
+                )
+        self.PutStream = channel.stream_unary(
+                '/proto.KV/PutStream',
+                request_serializer=kv__pb2.PutStreamRequest.SerializeToString,
+                response_deserializer=kv__pb2.Empty.FromString,
+                )
+        self.GetStream = channel.unary_stream(
+                '/proto.KV/GetStream',
+                request_serializer=kv__pb2.GetRequest.SerializeToString,
+                response_deserializer=kv__pb2.GetStreamResponse.FromString,
+                )
+        self.History = channel.unary_unary(
+                '/proto.KV/History',
+                request_serializer=kv__pb2.HistoryRequest.SerializeToString,
+                response_deserializer=kv__pb2.HistoryResponse.FromString,
+                )
+        self.GetRevision = channel.unary_unary(
+                '/proto.KV/GetRevision',
+                request_serializer=kv__pb2.RevisionRequest.SerializeToString,
+                response_deserializer=kv__pb2.GetResponse.FromString,
+                )
+        self.Rollback = channel.unary_unary(
+                '/proto.KV/Rollback',
+                request_serializer=kv__pb2.RevisionRequest.SerializeToString,
+                response_deserializer=kv__pb2.Empty.FromString,
+                )
+        self.Txn = channel.unary_unary(
+                '/proto.KV/Txn',
+                request_serializer=kv__pb2.TxnRequest.SerializeToString,
+                response_deserializer=kv__pb2.TxnResponse.FromString,
+                )
 
 
 class KVServicer(object):
@@ -44,6 +109,84 @@ class KVServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def List(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def Delete(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def Has(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def Watch(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def PutMany(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def GetMany(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def PutManyStream(self, request_iterator, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def PutStream(self, request_iterator, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def GetStream(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def History(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def GetRevision(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def Rollback(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
+    def Txn(self, request, context):
+        """Missing associated documentation comment in .proto file."""
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_KVServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -57,6 +200,71 @@ def add_KVServicer_to_server(servicer, server):
                     request_deserializer=kv__pb2.PutRequest.FromString,
                     response_serializer=kv__pb2.Empty.SerializeToString,
             ),
+            'List': grpc.unary_stream_rpc_method_handler(
+                    servicer.List,
+                    request_deserializer=kv__pb2.ListRequest.FromString,
+                    response_serializer=kv__pb2.ListResponse.SerializeToString,
+            ),
+            'Delete': grpc.unary_unary_rpc_method_handler(
+                    servicer.Delete,
+                    request_deserializer=kv__pb2.DeleteRequest.FromString,
+                    response_serializer=kv__pb2.Empty.SerializeToString,
+            ),
+            'Has': grpc.unary_unary_rpc_method_handler(
+                    servicer.Has,
+                    request_deserializer=kv__pb2.HasRequest.FromString,
+                    response_serializer=kv__pb2.HasResponse.SerializeToString,
+            ),
+            'Watch': grpc.unary_stream_rpc_method_handler(
+                    servicer.Watch,
+                    request_deserializer=kv__pb2.WatchRequest.FromString,
+                    response_serializer=kv__pb2.WatchEvent.SerializeToString,
+            ),
+            'PutMany': grpc.unary_unary_rpc_method_handler(
+                    servicer.PutMany,
+                    request_deserializer=kv__pb2.PutManyRequest.FromString,
+                    response_serializer=kv__pb2.Empty.SerializeToString,
+            ),
+            'GetMany': grpc.unary_unary_rpc_method_handler(
+                    servicer.GetMany,
+                    request_deserializer=kv__pb2.GetManyRequest.FromString,
+                    response_serializer=kv__pb2.GetManyResponse.SerializeToString,
+            ),
+            'PutManyStream': grpc.stream_unary_rpc_method_handler(
+                    servicer.PutManyStream,
+                    request_deserializer=kv__pb2.PutManyRequest.FromString,
+                    response_serializer=kv__pb2.Empty.SerializeToString,
+            ),
+            'PutStream': grpc.stream_unary_rpc_method_handler(
+                    servicer.PutStream,
+                    request_deserializer=kv__pb2.PutStreamRequest.FromString,
+                    response_serializer=kv__pb2.Empty.SerializeToString,
+            ),
+            'GetStream': grpc.unary_stream_rpc_method_handler(
+                    servicer.GetStream,
+                    request_deserializer=kv__pb2.GetRequest.FromString,
+                    response_serializer=kv__pb2.GetStreamResponse.SerializeToString,
+            ),
+            'History': grpc.unary_unary_rpc_method_handler(
+                    servicer.History,
+                    request_deserializer=kv__pb2.HistoryRequest.FromString,
+                    response_serializer=kv__pb2.HistoryResponse.SerializeToString,
+            ),
+            'GetRevision': grpc.unary_unary_rpc_method_handler(
+                    servicer.GetRevision,
+                    request_deserializer=kv__pb2.RevisionRequest.FromString,
+                    response_serializer=kv__pb2.GetResponse.SerializeToString,
+            ),
+            'Rollback': grpc.unary_unary_rpc_method_handler(
+                    servicer.Rollback,
+                    request_deserializer=kv__pb2.RevisionRequest.FromString,
+                    response_serializer=kv__pb2.Empty.SerializeToString,
+            ),
+            'Txn': grpc.unary_unary_rpc_method_handler(
+                    servicer.Txn,
+                    request_deserializer=kv__pb2.TxnRequest.FromString,
+                    response_serializer=kv__pb2.TxnResponse.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'proto.KV', rpc_method_handlers)
@@ -100,3 +308,224 @@ class KV(object):
             kv__pb2.Empty.FromString,
             options, channel_credentials,
             insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def List(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_stream(request, target, '/proto.KV/List',
+            kv__pb2.ListRequest.SerializeToString,
+            kv__pb2.ListResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Delete(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/proto.KV/Delete',
+            kv__pb2.DeleteRequest.SerializeToString,
+            kv__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Has(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/proto.KV/Has',
+            kv__pb2.HasRequest.SerializeToString,
+            kv__pb2.HasResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Watch(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_stream(request, target, '/proto.KV/Watch',
+            kv__pb2.WatchRequest.SerializeToString,
+            kv__pb2.WatchEvent.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def PutMany(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/proto.KV/PutMany',
+            kv__pb2.PutManyRequest.SerializeToString,
+            kv__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def GetMany(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/proto.KV/GetMany',
+            kv__pb2.GetManyRequest.SerializeToString,
+            kv__pb2.GetManyResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def PutManyStream(request_iterator,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.stream_unary(request_iterator, target, '/proto.KV/PutManyStream',
+            kv__pb2.PutManyRequest.SerializeToString,
+            kv__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def PutStream(request_iterator,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.stream_unary(request_iterator, target, '/proto.KV/PutStream',
+            kv__pb2.PutStreamRequest.SerializeToString,
+            kv__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def GetStream(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_stream(request, target, '/proto.KV/GetStream',
+            kv__pb2.GetRequest.SerializeToString,
+            kv__pb2.GetStreamResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def History(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/proto.KV/History',
+            kv__pb2.HistoryRequest.SerializeToString,
+            kv__pb2.HistoryResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def GetRevision(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/proto.KV/GetRevision',
+            kv__pb2.RevisionRequest.SerializeToString,
+            kv__pb2.GetResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Rollback(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/proto.KV/Rollback',
+            kv__pb2.RevisionRequest.SerializeToString,
+            kv__pb2.Empty.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
+
+    @staticmethod
+    def Txn(request,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.unary_unary(request, target, '/proto.KV/Txn',
+            kv__pb2.TxnRequest.SerializeToString,
+            kv__pb2.TxnResponse.FromString,
+            options, channel_credentials,
+            insecure, call_credentials, compression, wait_for_ready, timeout, metadata)
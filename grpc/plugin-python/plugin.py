@@ -2,7 +2,11 @@
 # SPDX-License-Identifier: MPL-2.0
 
 from concurrent import futures
+import os
+import shutil
 import sys
+import tempfile
+import threading
 import time
 
 import grpc
@@ -14,33 +18,352 @@ from grpc_health.v1.health import HealthServicer
 from grpc_health.v1 import health_pb2, health_pb2_grpc
 
 
+def validate_key(key, context):
+    """Abort the request with INVALID_ARGUMENT if the key is not safe to use in a filename."""
+    if not key:
+        context.abort(grpc.StatusCode.INVALID_ARGUMENT, "key must not be empty")
+    if any(c in key for c in "/\\\0"):
+        context.abort(grpc.StatusCode.INVALID_ARGUMENT, 'key "{0}" must not contain path separators'.format(key))
+
+
+def abort_with_os_error(key, err, context):
+    """Abort the request with the status code matching the OS error, which the host maps to an sdk.Error."""
+    if isinstance(err, FileNotFoundError):
+        context.abort(grpc.StatusCode.NOT_FOUND, 'key "{0}" not found'.format(key))
+    if isinstance(err, PermissionError):
+        context.abort(grpc.StatusCode.PERMISSION_DENIED, 'permission denied for key "{0}"'.format(key))
+    context.abort(grpc.StatusCode.INTERNAL, 'key "{0}": {1}'.format(key, err))
+
+
+# How often, in seconds, the files of expired keys are removed.
+SWEEP_INTERVAL = 60
+
+
+def expiry_filename(key):
+    """Return the name of the file holding the expiry time of the key, which starts with a dot so it is never listed as a key."""
+    return ".kv_py_" + key + ".expires"
+
+
+def is_expired(key):
+    """Report whether the key has an expiry time which has passed."""
+    try:
+        with open(expiry_filename(key), 'r') as f:
+            expires = float(f.read())
+    except (FileNotFoundError, ValueError):
+        return False
+    return time.time() >= expires
+
+
+def write_expiry(key, ttl_millis):
+    """Write the expiry time of the key, or remove it when the key has no TTL."""
+    filename = expiry_filename(key)
+    if ttl_millis <= 0:
+        try:
+            os.remove(filename)
+        except FileNotFoundError:
+            pass
+        return
+    with open(filename + ".tmp", 'w') as f:
+        f.write(str(time.time() + ttl_millis / 1000))
+    os.replace(filename + ".tmp", filename)
+
+
+# The environment variable giving how many revisions of each key are kept, and
+# the number kept when it is not set.
+REVISIONS_ENV = "KV_REVISIONS"
+DEFAULT_REVISIONS = 10
+
+
+def revisions_kept():
+    """Return the number of revisions kept of each key, set by the host application with the KV_REVISIONS environment variable."""
+    value = os.environ.get(REVISIONS_ENV, "")
+    if not value:
+        return DEFAULT_REVISIONS
+    if not (value.isascii() and value.isdigit()):
+        raise ValueError("{0} must be a number of revisions, given '{1}'".format(REVISIONS_ENV, value))
+    return int(value)
+
+
+def revision_filename(key, number):
+    """Return the name of the file holding the revision of the key, which starts with a dot so it is never listed as a key."""
+    return ".kv_py_{0}.{1}.rev".format(key, number)
+
+
+def revision_numbers(key):
+    """Return the sorted numbers of the revisions kept of the key.
+
+    Only a single number may follow the key, so the revisions of a key such as "a.1" are never taken for those of "a".
+    """
+    prefix = ".kv_py_" + key + "."
+    numbers = []
+    for filename in os.listdir("."):
+        if filename.startswith(prefix) and filename.endswith(".rev"):
+            number = filename[len(prefix):-len(".rev")]
+            if number.isascii() and number.isdigit() and int(number) > 0:
+                numbers.append(int(number))
+    return sorted(numbers)
+
+
+def write_value(key, value, revisions):
+    """Write the value to a temporary file, which replaces the file of the key once written in full."""
+    with tempfile.NamedTemporaryFile(dir=".", prefix=".kv_py_", suffix=".tmp", delete=False) as f:
+        f.write(value)
+    try:
+        commit_value(key, f.name, revisions)
+    finally:
+        if os.path.exists(f.name):
+            os.remove(f.name)
+
+
+def commit_value(key, tmp_filename, revisions):
+    """Keep the written temporary file as the next revision of the key, then replace the file of the key with it.
+
+    The revision is a hard link to the file where possible, so the value is not stored twice. The oldest revisions beyond
+    those kept are then removed.
+    """
+    if revisions > 0:
+        numbers = revision_numbers(key)
+        filename = revision_filename(key, numbers[-1] + 1 if numbers else 1)
+        try:
+            os.link(tmp_filename, filename)
+        except OSError:
+            shutil.copyfile(tmp_filename, filename)
+    os.replace(tmp_filename, "kv_py_" + key)
+    prune_revisions(key, revisions)
+
+
+def prune_revisions(key, revisions):
+    """Remove the oldest revisions of the key beyond the number kept, leaving any that can't be removed for the next write."""
+    numbers = revision_numbers(key)
+    for number in numbers[:max(len(numbers) - revisions, 0)]:
+        try:
+            os.remove(revision_filename(key, number))
+        except OSError:
+            pass
+
+
+def key_filenames(key):
+    """Return the names of all the files of the key: its value, expiry time, and revisions."""
+    return ["kv_py_" + key, expiry_filename(key)] + [revision_filename(key, n) for n in revision_numbers(key)]
+
+
+def sweep_expired():
+    """Remove the files of the expired keys, every SWEEP_INTERVAL seconds."""
+    while True:
+        time.sleep(SWEEP_INTERVAL)
+        for filename in os.listdir("."):
+            if filename.startswith(".kv_py_") and filename.endswith(".expires"):
+                key = filename[len(".kv_py_"):-len(".expires")]
+                if is_expired(key):
+                    for name in key_filenames(key):
+                        try:
+                            os.remove(name)
+                        except OSError:
+                            pass
+
+
 class KVServicer(kv_pb2_grpc.KVServicer):
     """Implementation of KV service."""
 
+    def __init__(self, revisions):
+        self.revisions = revisions
+
     def Get(self, request, context):
+        validate_key(request.key, context)
+        if is_expired(request.key):
+            context.abort(grpc.StatusCode.NOT_FOUND, 'key "{0}" not found'.format(request.key))
         filename = "kv_py_" + request.key
-        with open(filename, 'r+b') as f:
-            result = kv_pb2.GetResponse()
-            result.value = f.read()
-            return result
+        try:
+            with open(filename, 'r+b') as f:
+                result = kv_pb2.GetResponse()
+                result.value = f.read()
+                return result
+        except OSError as err:
+            abort_with_os_error(request.key, err, context)
 
     def Put(self, request, context):
+        """Write the value of the key, along with its expiry time when put with a TTL, which is written first."""
+        validate_key(request.key, context)
+        if request.ttl_millis < 0:
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, "ttl must not be negative")
+        value = request.value + b"\n\nWritten from plugin-python"
+        try:
+            write_expiry(request.key, request.ttl_millis)
+            write_value(request.key, value, self.revisions)
+        except OSError as err:
+            abort_with_os_error(request.key, err, context)
+
+        return kv_pb2.Empty()
+
+    def List(self, request, context):
+        prefix = "kv_py_"
+        for filename in sorted(os.listdir(".")):
+            if os.path.isfile(filename) and filename.startswith(prefix + request.prefix) \
+                    and not is_expired(filename[len(prefix):]):
+                yield kv_pb2.ListResponse(key=filename[len(prefix):])
+
+    def Delete(self, request, context):
+        validate_key(request.key, context)
+        for filename in key_filenames(request.key):
+            try:
+                os.remove(filename)
+            except FileNotFoundError:
+                pass
+            except OSError as err:
+                abort_with_os_error(request.key, err, context)
+
+        return kv_pb2.Empty()
+
+    def Has(self, request, context):
+        validate_key(request.key, context)
+        filename = "kv_py_" + request.key
+        return kv_pb2.HasResponse(exists=os.path.isfile(filename) and not is_expired(request.key))
+
+    def PutMany(self, request, context):
+        self.put_items(request.items, context)
+        return kv_pb2.Empty()
+
+    def GetMany(self, request, context):
+        result = kv_pb2.GetManyResponse()
+        for key in request.keys:
+            validate_key(key, context)
+            if is_expired(key):
+                continue
+            try:
+                with open("kv_py_" + key, 'r+b') as f:
+                    result.items.add(key=key, value=f.read())
+            except FileNotFoundError:
+                pass
+            except OSError as err:
+                abort_with_os_error(key, err, context)
+        return result
+
+    def PutManyStream(self, request_iterator, context):
+        """Put each part of a batch streamed by the host as it arrives."""
+        for request in request_iterator:
+            self.put_items(request.items, context)
+        return kv_pb2.Empty()
+
+    def PutStream(self, request_iterator, context):
+        """Write the streamed value to a temporary file, which replaces the file of the key once the whole value is received."""
+        key = None
+        f = None
+        try:
+            for request in request_iterator:
+                if f is None:
+                    key = request.key
+                    validate_key(key, context)
+                    # Each stream has its own temporary file, so concurrent puts of the key don't write to the same file.
+                    f = tempfile.NamedTemporaryFile(dir=".", prefix=".kv_py_", suffix=".tmp", delete=False)
+                f.write(request.chunk)
+            if f is None:
+                context.abort(grpc.StatusCode.INVALID_ARGUMENT, "no key was sent")
+            f.write(b"\n\nWritten from plugin-python")
+            f.close()
+            write_expiry(key, 0)
+            commit_value(key, f.name, self.revisions)
+        except OSError as err:
+            abort_with_os_error(key, err, context)
+        finally:
+            if f is not None:
+                f.close()
+                if os.path.exists(f.name):
+                    os.remove(f.name)
+
+        return kv_pb2.Empty()
+
+    def GetStream(self, request, context):
+        """Send the file of the key in chunks, so it is never read into memory in full."""
+        validate_key(request.key, context)
+        if is_expired(request.key):
+            context.abort(grpc.StatusCode.NOT_FOUND, 'key "{0}" not found'.format(request.key))
         filename = "kv_py_" + request.key
-        value = "{0}\n\nWritten from plugin-python".format(request.value)
-        with open(filename, 'w') as f:
-            f.write(value)
+        try:
+            with open(filename, 'rb') as f:
+                while True:
+                    chunk = f.read(64 * 1024)
+                    if not chunk:
+                        break
+                    yield kv_pb2.GetStreamResponse(chunk=chunk)
+        except OSError as err:
+            abort_with_os_error(request.key, err, context)
+
+    def History(self, request, context):
+        """Return the revisions kept of the key, oldest first, with the last being the current value."""
+        validate_key(request.key, context)
+        if not os.path.isfile("kv_py_" + request.key) or is_expired(request.key):
+            context.abort(grpc.StatusCode.NOT_FOUND, 'key "{0}" not found'.format(request.key))
+        result = kv_pb2.HistoryResponse()
+        for number in revision_numbers(request.key):
+            try:
+                info = os.stat(revision_filename(request.key, number))
+            except FileNotFoundError:
+                # removed by another put since the directory was listed.
+                continue
+            except OSError as err:
+                abort_with_os_error(request.key, err, context)
+            result.revisions.add(number=number, time=info.st_mtime_ns, size=info.st_size)
+        return result
+
+    def GetRevision(self, request, context):
+        validate_key(request.key, context)
+        result = kv_pb2.GetResponse()
+        result.value = self.read_revision(request.key, request.revision, context)
+        return result
+
+    def Rollback(self, request, context):
+        """Put the value of the key at the revision again, as a new revision, so no revision is lost."""
+        validate_key(request.key, context)
+        value = self.read_revision(request.key, request.revision, context)
+        try:
+            write_expiry(request.key, 0)
+            write_value(request.key, value, self.revisions)
+        except OSError as err:
+            abort_with_os_error(request.key, err, context)
 
         return kv_pb2.Empty()
 
+    def read_revision(self, key, revision, context):
+        """Return the value of the key at the revision, aborting the request when the revision is not kept."""
+        try:
+            with open(revision_filename(key, revision), 'rb') as f:
+                return f.read()
+        except FileNotFoundError:
+            context.abort(grpc.StatusCode.NOT_FOUND, 'revision {0} of key "{1}" not found'.format(revision, key))
+        except OSError as err:
+            abort_with_os_error(key, err, context)
+
+    def put_items(self, items, context):
+        """Check every key before writing any of the files, so a batch with an invalid key is refused as a whole."""
+        for item in items:
+            validate_key(item.key, context)
+        for item in items:
+            value = item.value + b"\n\nWritten from plugin-python"
+            try:
+                write_expiry(item.key, 0)
+                write_value(item.key, value, self.revisions)
+            except OSError as err:
+                abort_with_os_error(item.key, err, context)
+
 
 def serve():
     # We need to build a health service to work with go-plugin
     health = HealthServicer()
     health.set("plugin", health_pb2.HealthCheckResponse.ServingStatus.Value('SERVING'))
 
+    # The number of revisions kept of each key is set by the host application.
+    try:
+        revisions = revisions_kept()
+    except ValueError as err:
+        print(err, file=sys.stderr)
+        sys.exit(1)
+
+    # Expired keys are hidden, with their files being removed in the background.
+    threading.Thread(target=sweep_expired, daemon=True).start()
+
     # Start the server.
     server = grpc.server(futures.ThreadPoolExecutor(max_workers=10))
-    kv_pb2_grpc.add_KVServicer_to_server(KVServicer(), server)
+    kv_pb2_grpc.add_KVServicer_to_server(KVServicer(revisions), server)
     health_pb2_grpc.add_HealthServicer_to_server(health, server)
     server.add_insecure_port('127.0.0.1:1234')
     server.start()
@@ -22,8 +22,21 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	KV_Get_FullMethodName = "/proto.KV/Get"
-	KV_Put_FullMethodName = "/proto.KV/Put"
+	KV_Get_FullMethodName           = "/proto.KV/Get"
+	KV_Put_FullMethodName           = "/proto.KV/Put"
+	KV_List_FullMethodName          = "/proto.KV/List"
+	KV_Delete_FullMethodName        = "/proto.KV/Delete"
+	KV_Has_FullMethodName           = "/proto.KV/Has"
+	KV_Watch_FullMethodName         = "/proto.KV/Watch"
+	KV_PutMany_FullMethodName       = "/proto.KV/PutMany"
+	KV_GetMany_FullMethodName       = "/proto.KV/GetMany"
+	KV_PutManyStream_FullMethodName = "/proto.KV/PutManyStream"
+	KV_PutStream_FullMethodName     = "/proto.KV/PutStream"
+	KV_GetStream_FullMethodName     = "/proto.KV/GetStream"
+	KV_History_FullMethodName       = "/proto.KV/History"
+	KV_GetRevision_FullMethodName   = "/proto.KV/GetRevision"
+	KV_Rollback_FullMethodName      = "/proto.KV/Rollback"
+	KV_Txn_FullMethodName           = "/proto.KV/Txn"
 )
 
 // KVClient is the client API for KV service.
@@ -32,6 +45,19 @@ const (
 type KVClient interface {
 	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
 	Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOption) (*Empty, error)
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (KV_ListClient, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Empty, error)
+	Has(ctx context.Context, in *HasRequest, opts ...grpc.CallOption) (*HasResponse, error)
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KV_WatchClient, error)
+	PutMany(ctx context.Context, in *PutManyRequest, opts ...grpc.CallOption) (*Empty, error)
+	GetMany(ctx context.Context, in *GetManyRequest, opts ...grpc.CallOption) (*GetManyResponse, error)
+	PutManyStream(ctx context.Context, opts ...grpc.CallOption) (KV_PutManyStreamClient, error)
+	PutStream(ctx context.Context, opts ...grpc.CallOption) (KV_PutStreamClient, error)
+	GetStream(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (KV_GetStreamClient, error)
+	History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error)
+	GetRevision(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Rollback(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*Empty, error)
+	Txn(ctx context.Context, in *TxnRequest, opts ...grpc.CallOption) (*TxnResponse, error)
 }
 
 type kVClient struct {
@@ -60,12 +86,261 @@ func (c *kVClient) Put(ctx context.Context, in *PutRequest, opts ...grpc.CallOpt
 	return out, nil
 }
 
+func (c *kVClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (KV_ListClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KV_ServiceDesc.Streams[0], KV_List_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVListClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_ListClient interface {
+	Recv() (*ListResponse, error)
+	grpc.ClientStream
+}
+
+type kVListClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVListClient) Recv() (*ListResponse, error) {
+	m := new(ListResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, KV_Delete_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Has(ctx context.Context, in *HasRequest, opts ...grpc.CallOption) (*HasResponse, error) {
+	out := new(HasResponse)
+	err := c.cc.Invoke(ctx, KV_Has_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (KV_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KV_ServiceDesc.Streams[1], KV_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type kVWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) PutMany(ctx context.Context, in *PutManyRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, KV_PutMany_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) GetMany(ctx context.Context, in *GetManyRequest, opts ...grpc.CallOption) (*GetManyResponse, error) {
+	out := new(GetManyResponse)
+	err := c.cc.Invoke(ctx, KV_GetMany_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) PutManyStream(ctx context.Context, opts ...grpc.CallOption) (KV_PutManyStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KV_ServiceDesc.Streams[2], KV_PutManyStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVPutManyStreamClient{stream}
+	return x, nil
+}
+
+type KV_PutManyStreamClient interface {
+	Send(*PutManyRequest) error
+	CloseAndRecv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type kVPutManyStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVPutManyStreamClient) Send(m *PutManyRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kVPutManyStreamClient) CloseAndRecv() (*Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) PutStream(ctx context.Context, opts ...grpc.CallOption) (KV_PutStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KV_ServiceDesc.Streams[3], KV_PutStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVPutStreamClient{stream}
+	return x, nil
+}
+
+type KV_PutStreamClient interface {
+	Send(*PutStreamRequest) error
+	CloseAndRecv() (*Empty, error)
+	grpc.ClientStream
+}
+
+type kVPutStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVPutStreamClient) Send(m *PutStreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *kVPutStreamClient) CloseAndRecv() (*Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) GetStream(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (KV_GetStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &KV_ServiceDesc.Streams[4], KV_GetStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVGetStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_GetStreamClient interface {
+	Recv() (*GetStreamResponse, error)
+	grpc.ClientStream
+}
+
+type kVGetStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVGetStreamClient) Recv() (*GetStreamResponse, error) {
+	m := new(GetStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) History(ctx context.Context, in *HistoryRequest, opts ...grpc.CallOption) (*HistoryResponse, error) {
+	out := new(HistoryResponse)
+	err := c.cc.Invoke(ctx, KV_History_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) GetRevision(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, KV_GetRevision_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Rollback(ctx context.Context, in *RevisionRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, KV_Rollback_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *kVClient) Txn(ctx context.Context, in *TxnRequest, opts ...grpc.CallOption) (*TxnResponse, error) {
+	out := new(TxnResponse)
+	err := c.cc.Invoke(ctx, KV_Txn_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // KVServer is the server API for KV service.
 // All implementations must embed UnimplementedKVServer
 // for forward compatibility
 type KVServer interface {
 	Get(context.Context, *GetRequest) (*GetResponse, error)
 	Put(context.Context, *PutRequest) (*Empty, error)
+	List(*ListRequest, KV_ListServer) error
+	Delete(context.Context, *DeleteRequest) (*Empty, error)
+	Has(context.Context, *HasRequest) (*HasResponse, error)
+	Watch(*WatchRequest, KV_WatchServer) error
+	PutMany(context.Context, *PutManyRequest) (*Empty, error)
+	GetMany(context.Context, *GetManyRequest) (*GetManyResponse, error)
+	PutManyStream(KV_PutManyStreamServer) error
+	PutStream(KV_PutStreamServer) error
+	GetStream(*GetRequest, KV_GetStreamServer) error
+	History(context.Context, *HistoryRequest) (*HistoryResponse, error)
+	GetRevision(context.Context, *RevisionRequest) (*GetResponse, error)
+	Rollback(context.Context, *RevisionRequest) (*Empty, error)
+	Txn(context.Context, *TxnRequest) (*TxnResponse, error)
 	mustEmbedUnimplementedKVServer()
 }
 
@@ -79,6 +354,45 @@ func (UnimplementedKVServer) Get(context.Context, *GetRequest) (*GetResponse, er
 func (UnimplementedKVServer) Put(context.Context, *PutRequest) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
 }
+func (UnimplementedKVServer) List(*ListRequest, KV_ListServer) error {
+	return status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedKVServer) Delete(context.Context, *DeleteRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (UnimplementedKVServer) Has(context.Context, *HasRequest) (*HasResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Has not implemented")
+}
+func (UnimplementedKVServer) Watch(*WatchRequest, KV_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedKVServer) PutMany(context.Context, *PutManyRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PutMany not implemented")
+}
+func (UnimplementedKVServer) GetMany(context.Context, *GetManyRequest) (*GetManyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMany not implemented")
+}
+func (UnimplementedKVServer) PutManyStream(KV_PutManyStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method PutManyStream not implemented")
+}
+func (UnimplementedKVServer) PutStream(KV_PutStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method PutStream not implemented")
+}
+func (UnimplementedKVServer) GetStream(*GetRequest, KV_GetStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetStream not implemented")
+}
+func (UnimplementedKVServer) History(context.Context, *HistoryRequest) (*HistoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method History not implemented")
+}
+func (UnimplementedKVServer) GetRevision(context.Context, *RevisionRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRevision not implemented")
+}
+func (UnimplementedKVServer) Rollback(context.Context, *RevisionRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rollback not implemented")
+}
+func (UnimplementedKVServer) Txn(context.Context, *TxnRequest) (*TxnResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Txn not implemented")
+}
 func (UnimplementedKVServer) mustEmbedUnimplementedKVServer() {}
 
 // UnsafeKVServer may be embedded to opt out of forward compatibility for this service.
@@ -128,6 +442,265 @@ func _KV_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{})
 	return interceptor(ctx, in, info, handler)
 }
 
+func _KV_List_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).List(m, &kVListServer{stream})
+}
+
+type KV_ListServer interface {
+	Send(*ListResponse) error
+	grpc.ServerStream
+}
+
+type kVListServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVListServer) Send(m *ListResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KV_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KV_Delete_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Has_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HasRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Has(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KV_Has_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Has(ctx, req.(*HasRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).Watch(m, &kVWatchServer{stream})
+}
+
+type KV_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type kVWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KV_PutMany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutManyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).PutMany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KV_PutMany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).PutMany(ctx, req.(*PutManyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_GetMany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetManyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).GetMany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KV_GetMany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).GetMany(ctx, req.(*GetManyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_PutManyStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KVServer).PutManyStream(&kVPutManyStreamServer{stream})
+}
+
+type KV_PutManyStreamServer interface {
+	SendAndClose(*Empty) error
+	Recv() (*PutManyRequest, error)
+	grpc.ServerStream
+}
+
+type kVPutManyStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVPutManyStreamServer) SendAndClose(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kVPutManyStreamServer) Recv() (*PutManyRequest, error) {
+	m := new(PutManyRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _KV_PutStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KVServer).PutStream(&kVPutStreamServer{stream})
+}
+
+type KV_PutStreamServer interface {
+	SendAndClose(*Empty) error
+	Recv() (*PutStreamRequest, error)
+	grpc.ServerStream
+}
+
+type kVPutStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVPutStreamServer) SendAndClose(m *Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *kVPutStreamServer) Recv() (*PutStreamRequest, error) {
+	m := new(PutStreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _KV_GetStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).GetStream(m, &kVGetStreamServer{stream})
+}
+
+type KV_GetStreamServer interface {
+	Send(*GetStreamResponse) error
+	grpc.ServerStream
+}
+
+type kVGetStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVGetStreamServer) Send(m *GetStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KV_History_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HistoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).History(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KV_History_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).History(ctx, req.(*HistoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_GetRevision_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).GetRevision(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KV_GetRevision_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).GetRevision(ctx, req.(*RevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Rollback_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevisionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Rollback(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KV_Rollback_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Rollback(ctx, req.(*RevisionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _KV_Txn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TxnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KVServer).Txn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: KV_Txn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KVServer).Txn(ctx, req.(*TxnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // KV_ServiceDesc is the grpc.ServiceDesc for KV service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -143,7 +716,65 @@ var KV_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Put",
 			Handler:    _KV_Put_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _KV_Delete_Handler,
+		},
+		{
+			MethodName: "Has",
+			Handler:    _KV_Has_Handler,
+		},
+		{
+			MethodName: "PutMany",
+			Handler:    _KV_PutMany_Handler,
+		},
+		{
+			MethodName: "GetMany",
+			Handler:    _KV_GetMany_Handler,
+		},
+		{
+			MethodName: "History",
+			Handler:    _KV_History_Handler,
+		},
+		{
+			MethodName: "GetRevision",
+			Handler:    _KV_GetRevision_Handler,
+		},
+		{
+			MethodName: "Rollback",
+			Handler:    _KV_Rollback_Handler,
+		},
+		{
+			MethodName: "Txn",
+			Handler:    _KV_Txn_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "List",
+			Handler:       _KV_List_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _KV_Watch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutManyStream",
+			Handler:       _KV_PutManyStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "PutStream",
+			Handler:       _KV_PutStream_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetStream",
+			Handler:       _KV_GetStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/kv.proto",
 }
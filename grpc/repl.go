@@ -0,0 +1,183 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mrcook/go-plugin-examples/discovery"
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/repl"
+	"github.com/mrcook/go-plugin-examples/supervisor"
+)
+
+// replSession holds the plugin used by the REPL, which is replaced by the
+// switch-plugin command.
+type replSession struct {
+	args       cliArgs
+	pluginName string
+	supervisor *supervisor.Supervisor
+	kv         *sdk.SupervisedKVStore
+	repl       *repl.REPL
+}
+
+// runREPL reads commands until the user exits, making each request to the
+// same plugin process. The plugin in use when the REPL exits is stopped.
+func runREPL(kvSupervisor *supervisor.Supervisor, args cliArgs) error {
+	s := &replSession{
+		args:       args,
+		pluginName: args.pluginName,
+		supervisor: kvSupervisor,
+		kv:         sdk.NewSupervisedKVStore(kvSupervisor),
+	}
+	defer func() { s.supervisor.Kill() }()
+
+	s.repl = repl.New(repl.Config{
+		Prompt:      "kv> ",
+		HistoryFile: repl.HistoryFile("kv_grpc"),
+		Commands: []repl.Command{
+			{Name: "get", Args: "<key>", Help: "print the value of the key", MinArgs: 1, Run: s.get},
+			{Name: "put", Args: "<key> <value>", Help: "save the value for the key", MinArgs: 2, Run: s.put},
+			{Name: "list", Args: "[prefix]", Help: "list the keys starting with the prefix", Run: s.list},
+			{Name: "delete", Args: "<key>", Help: "delete the key", MinArgs: 1, Run: s.delete},
+			{Name: "has", Args: "<key>", Help: "print whether the key exists", MinArgs: 1, Run: s.has},
+			{Name: "import", Args: "<file>", Help: "put the key/value pairs of a JSON or CSV file", MinArgs: 1, Run: s.importFile},
+			{Name: "put-file", Args: "<key> <file>", Help: "save the contents of the file for the key", MinArgs: 2, Run: s.putFile},
+			{Name: "get-file", Args: "<key> <file>", Help: "write the value of the key to the file", MinArgs: 2, Run: s.getFile},
+			{Name: "history", Args: "<key>", Help: "print the revisions kept of the key", MinArgs: 1, Run: s.history},
+			{Name: "get-revision", Args: "<key> <revision>", Help: "print the value of the key at the revision", MinArgs: 2, Run: s.getRevision},
+			{Name: "rollback", Args: "<key> <revision>", Help: "put the value of the key at the revision again", MinArgs: 2, Run: s.rollback},
+			{Name: "txn", Args: "<step>...", Help: "apply the puts and deletes if the conditions hold, e.g. if-absent:lock put:lock=me", MinArgs: 1, Run: s.txn},
+			{Name: "switch-plugin", Args: "<name>", Help: "switch to another plugin, e.g. grpc, rpc, or python", MinArgs: 1, Completions: s.pluginNames(), Run: s.switchPlugin},
+			{Name: "stats", Help: "print the plugin in use, and the time taken by each command", Run: s.stats},
+		},
+	})
+	return s.repl.Run()
+}
+
+// commandArgs returns the arguments of the command, with the flags given when
+// the REPL was started, such as the --timeout and --ttl.
+func (s *replSession) commandArgs(command, key, value string) cliArgs {
+	args := s.args
+	args.command = command
+	args.key = key
+	args.value = value
+	return args
+}
+
+func (s *replSession) get(args []string) error {
+	return doCommand(s.kv, s.commandArgs("get", args[0], ""))
+}
+
+func (s *replSession) put(args []string) error {
+	// The value is everything following the key.
+	return doCommand(s.kv, s.commandArgs("put", args[0], strings.Join(args[1:], " ")))
+}
+
+func (s *replSession) list(args []string) error {
+	var prefix string
+	if len(args) > 0 {
+		prefix = args[0]
+	}
+	return doCommand(s.kv, s.commandArgs("list", prefix, ""))
+}
+
+func (s *replSession) delete(args []string) error {
+	return doCommand(s.kv, s.commandArgs("delete", args[0], ""))
+}
+
+func (s *replSession) has(args []string) error {
+	return doCommand(s.kv, s.commandArgs("has", args[0], ""))
+}
+
+func (s *replSession) importFile(args []string) error {
+	return doCommand(s.kv, s.commandArgs("import", args[0], ""))
+}
+
+func (s *replSession) putFile(args []string) error {
+	return doCommand(s.kv, s.commandArgs("put-file", args[0], args[1]))
+}
+
+func (s *replSession) getFile(args []string) error {
+	return doCommand(s.kv, s.commandArgs("get-file", args[0], args[1]))
+}
+
+func (s *replSession) history(args []string) error {
+	return doCommand(s.kv, s.commandArgs("history", args[0], ""))
+}
+
+func (s *replSession) getRevision(args []string) error {
+	return s.revisionCommand("get-revision", args)
+}
+
+func (s *replSession) rollback(args []string) error {
+	return s.revisionCommand("rollback", args)
+}
+
+// revisionCommand runs the get-revision or rollback command, given the key and
+// the revision.
+func (s *replSession) revisionCommand(command string, args []string) error {
+	revision, err := parseRevision(args[1])
+	if err != nil {
+		return err
+	}
+	cmdArgs := s.commandArgs(command, args[0], "")
+	cmdArgs.revision = revision
+	return doCommand(s.kv, cmdArgs)
+}
+
+func (s *replSession) txn(args []string) error {
+	txn, err := parseTxn(args)
+	if err != nil {
+		return err
+	}
+	cmdArgs := s.commandArgs("txn", "", "")
+	cmdArgs.txn = txn
+	return doCommand(s.kv, cmdArgs)
+}
+
+// switchPlugin starts the named plugin, and only once it is running, stops
+// the plugin that was in use.
+func (s *replSession) switchPlugin(args []string) error {
+	pluginName := resolvePluginName(args[0])
+
+	kvSupervisor, err := startPlugin(pluginName, s.args.pluginDirs)
+	if err != nil {
+		return err
+	}
+	s.supervisor.Kill()
+
+	s.pluginName = pluginName
+	s.supervisor = kvSupervisor
+	s.kv = sdk.NewSupervisedKVStore(kvSupervisor)
+	fmt.Println("Switched to plugin", pluginName)
+	return nil
+}
+
+func (s *replSession) stats(_ []string) error {
+	fmt.Printf("Plugin %s, restarted %d times\n\n", s.pluginName, s.kv.Restarts())
+	s.repl.WriteStats(os.Stdout)
+	return nil
+}
+
+// pluginNames returns the names completed by the switch-plugin command: the
+// shortcuts, the Python plugin, and the discovered plugins.
+func (s *replSession) pluginNames() []string {
+	names := []string{pythonPluginName}
+	for alias := range pluginAliases {
+		names = append(names, alias)
+	}
+	registry, err := discovery.Discover(discovery.Config{
+		Dirs:    discovery.SplitDirs(s.args.pluginDirs),
+		Pattern: pluginPattern,
+	})
+	if err == nil {
+		names = append(names, registry.Names()...)
+	}
+	sort.Strings(names)
+	return names
+}
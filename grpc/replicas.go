@@ -0,0 +1,57 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"bytes"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+	"github.com/mrcook/go-plugin-examples/supervisor"
+)
+
+// startReplicas starts each of the replica plugins, returning a KV store
+// replicated across them, and a function to stop the plugins.
+func startReplicas(args cliArgs) (*sdk.ReplicatedKVStore, func(), error) {
+	var supervisors []*supervisor.Supervisor
+	stop := func() {
+		for _, s := range supervisors {
+			s.Kill()
+		}
+	}
+
+	var replicas []sdk.ContextKVStore
+	for _, name := range args.replicaNames {
+		kvSupervisor, err := startPlugin(name, args.pluginDirs)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		supervisors = append(supervisors, kvSupervisor)
+		replicas = append(replicas, sdk.NewSupervisedKVStore(kvSupervisor))
+	}
+
+	kv, err := sdk.NewReplicatedKVStore(replicas, sdk.ReplicationConfig{
+		WriteQuorum: args.writeQuorum,
+		ReadQuorum:  args.readQuorum,
+		Normalize:   stripPluginNote,
+	})
+	if err != nil {
+		stop()
+		return nil, nil, err
+	}
+	return kv, stop, nil
+}
+
+// pluginNote is added by each of the example plugins to the values they
+// store, e.g. "Written from plugin-go-grpc".
+var pluginNote = []byte("\n\nWritten from ")
+
+// stripPluginNote removes the note added by the plugin, so the values stored
+// by different plugins can be compared.
+func stripPluginNote(value []byte) []byte {
+	if i := bytes.LastIndex(value, pluginNote); i >= 0 {
+		return value[:i]
+	}
+	return value
+}
@@ -0,0 +1,121 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"errors"
+)
+
+// KeyValue is a key along with its value, as put and returned by the batch
+// requests.
+type KeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// BatchKVStore is implemented by KVStore plugins that can put and get many
+// keys in a single request, e.g. by writing them in one transaction.
+//
+// Plugins need not implement it: the sdk then puts and gets the keys of a
+// batch one at a time, within the plugin, so the batch still only takes a
+// single round trip between the host and plugin.
+type BatchKVStore interface {
+	// PutMany puts the value of every item. The items are not put
+	// atomically, so some of them may have been put when an error is
+	// returned.
+	PutMany(items []KeyValue) error
+
+	// GetMany returns the items for the keys found in the store, in the
+	// order of the keys. Keys that are not found are left out.
+	GetMany(keys []string) ([]KeyValue, error)
+}
+
+// ContextBatchKVStore is the context-aware variant of the BatchKVStore
+// interface.
+//
+// The clients dispensed to host applications implement this interface. Over
+// gRPC, a batch too large to send in a single message is streamed to the
+// plugin in parts, with each part being put as it arrives.
+type ContextBatchKVStore interface {
+	PutManyContext(ctx context.Context, items []KeyValue) error
+	GetManyContext(ctx context.Context, keys []string) ([]KeyValue, error)
+}
+
+// PutMany puts the items in the store in a single request when the store is
+// a ContextBatchKVStore, otherwise they are put one at a time.
+func PutMany(ctx context.Context, kv ContextKVStore, items []KeyValue) error {
+	if batch, ok := kv.(ContextBatchKVStore); ok {
+		return batch.PutManyContext(ctx, items)
+	}
+	for _, item := range items {
+		if err := kv.PutContext(ctx, item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMany returns the items for the keys found in the store, using a single
+// request when the store is a ContextBatchKVStore, otherwise the keys are
+// got one at a time.
+func GetMany(ctx context.Context, kv ContextKVStore, keys []string) ([]KeyValue, error) {
+	if batch, ok := kv.(ContextBatchKVStore); ok {
+		return batch.GetManyContext(ctx, keys)
+	}
+	var items []KeyValue
+	for _, key := range keys {
+		value, err := kv.GetContext(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		items = append(items, KeyValue{Key: key, Value: value})
+	}
+	return items, nil
+}
+
+// putMany is used by the servers to put a batch using the plugin's Impl,
+// calling the most capable of the interfaces it implements.
+func putMany(ctx context.Context, impl KVStore, items []KeyValue) error {
+	switch impl := impl.(type) {
+	case ContextBatchKVStore:
+		return impl.PutManyContext(ctx, items)
+	case BatchKVStore:
+		return impl.PutMany(items)
+	case ContextKVStore:
+		return PutMany(ctx, impl, items)
+	}
+	for _, item := range items {
+		if err := impl.Put(item.Key, item.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getMany is used by the servers to get a batch using the plugin's Impl,
+// calling the most capable of the interfaces it implements.
+func getMany(ctx context.Context, impl KVStore, keys []string) ([]KeyValue, error) {
+	switch impl := impl.(type) {
+	case ContextBatchKVStore:
+		return impl.GetManyContext(ctx, keys)
+	case BatchKVStore:
+		return impl.GetMany(keys)
+	case ContextKVStore:
+		return GetMany(ctx, impl, keys)
+	}
+	var items []KeyValue
+	for _, key := range keys {
+		value, err := impl.Get(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		items = append(items, KeyValue{Key: key, Value: value})
+	}
+	return items, nil
+}
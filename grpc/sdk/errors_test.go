@@ -0,0 +1,104 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mrcook/go-plugin-examples/storage"
+)
+
+// pluginErrors are errors a plugin may return, with the Error expected by the
+// host for each of them.
+var pluginErrors = []struct {
+	name string
+	err  error
+	want *Error
+}{
+	{"not found", NewError(NotFound, "key %q not found", "a"), &Error{Code: NotFound, Message: `key "a" not found`}},
+	{"invalid key", NewError(InvalidKey, "key must not be empty"), &Error{Code: InvalidKey, Message: "key must not be empty"}},
+	{"permission denied", NewError(PermissionDenied, "no"), &Error{Code: PermissionDenied, Message: "no"}},
+	{"internal", NewError(Internal, "disk full"), &Error{Code: Internal, Message: "disk full"}},
+	{"partially applied", NewError(PartiallyApplied, "a: b"), &Error{Code: PartiallyApplied, Message: "a: b"}},
+	{"message with colons", NewError(NotFound, "a:b:c"), &Error{Code: NotFound, Message: "a:b:c"}},
+	{"wrapped", fmt.Errorf("putting: %w", NewError(InvalidKey, "bad")), &Error{Code: InvalidKey, Message: "bad"}},
+	{"untyped", errors.New("boom"), &Error{Code: Internal, Message: "boom"}},
+	{"storage invalid key", storage.ValidateKey(""), &Error{Code: InvalidKey, Message: "key must not be empty"}},
+}
+
+func TestStatusRoundTrip(t *testing.T) {
+	for _, tt := range pluginErrors {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fromStatus(toStatus(tt.err))
+			checkError(t, got, tt.want)
+		})
+	}
+}
+
+func TestRPCErrorRoundTrip(t *testing.T) {
+	for _, tt := range pluginErrors {
+		t.Run(tt.name, func(t *testing.T) {
+			// The net/rpc client only receives the message of the error.
+			got := fromRPCError(rpc.ServerError(toRPCError(tt.err).Error()))
+			checkError(t, got, tt.want)
+		})
+	}
+}
+
+func TestContextErrorRoundTrip(t *testing.T) {
+	for _, err := range []error{context.DeadlineExceeded, context.Canceled} {
+		if got := fromStatus(toStatus(err)); !errors.Is(got, err) {
+			t.Errorf("fromStatus(toStatus(%v)) = %v, want %v", err, got, err)
+		}
+	}
+}
+
+func TestTransportErrorsAreWrapped(t *testing.T) {
+	tests := []struct {
+		name   string
+		got    error
+		target error
+	}{
+		{"net/rpc shutdown", fromRPCError(rpc.ErrShutdown), rpc.ErrShutdown},
+		{"net/rpc unexpected EOF", fromRPCError(io.ErrUnexpectedEOF), io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.got, tt.target) {
+				t.Errorf("errors.Is(%v, %v) = false, want true", tt.got, tt.target)
+			}
+			if !errors.Is(tt.got, ErrInternal) {
+				t.Errorf("errors.Is(%v, ErrInternal) = false, want true", tt.got)
+			}
+		})
+	}
+
+	unavailable := status.Error(codes.Unavailable, "connection refused")
+	got := fromStatus(unavailable)
+	if !errors.Is(got, ErrInternal) || status.Code(errors.Unwrap(got)) != codes.Unavailable {
+		t.Errorf("fromStatus(%v) = %v, want an Internal error wrapping it", unavailable, got)
+	}
+}
+
+func checkError(t *testing.T, got error, want *Error) {
+	t.Helper()
+	var e *Error
+	if !errors.As(got, &e) {
+		t.Fatalf("error = %#v, want an *Error", got)
+	}
+	if e.Code != want.Code || e.Message != want.Message {
+		t.Errorf("error = %s %q, want %s %q", e.Code, e.Message, want.Code, want.Message)
+	}
+	if !errors.Is(got, &Error{Code: want.Code}) {
+		t.Errorf("errors.Is(%v, %s) = false, want true", got, want.Code)
+	}
+}
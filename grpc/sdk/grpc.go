@@ -5,46 +5,642 @@
 package sdk
 
 import (
+	"bufio"
 	"context"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/mrcook/go-plugin-examples/grpc/proto"
 )
 
 // grpcClient is an implementation of KVStore that talks over RPC.
+// It also implements ContextKVStore, with gRPC sending any deadline set on
+// the context along with the request.
 type grpcClient struct {
 	client proto.KVClient
 }
 
 func (c *grpcClient) Put(key string, value []byte) error {
-	_, err := c.client.Put(context.Background(), &proto.PutRequest{
+	return c.PutContext(context.Background(), key, value)
+}
+
+func (c *grpcClient) Get(key string) ([]byte, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+func (c *grpcClient) List(prefix string) ([]string, error) {
+	return c.ListContext(context.Background(), prefix)
+}
+
+func (c *grpcClient) Delete(key string) error {
+	return c.DeleteContext(context.Background(), key)
+}
+
+func (c *grpcClient) Has(key string) (bool, error) {
+	return c.HasContext(context.Background(), key)
+}
+
+func (c *grpcClient) PutContext(ctx context.Context, key string, value []byte) error {
+	_, err := c.client.Put(ctx, &proto.PutRequest{
 		Key:   key,
 		Value: value,
 	})
-	return err
+	return fromStatus(err)
 }
 
-func (c *grpcClient) Get(key string) ([]byte, error) {
-	resp, err := c.client.Get(context.Background(), &proto.GetRequest{
+func (c *grpcClient) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return c.PutWithTTLContext(context.Background(), key, value, ttl)
+}
+
+func (c *grpcClient) PutWithTTLContext(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	millis, err := ttlMillis(ttl)
+	if err != nil {
+		return err
+	}
+	_, err = c.client.Put(ctx, &proto.PutRequest{
+		Key:       key,
+		Value:     value,
+		TtlMillis: millis,
+	})
+	return fromStatus(err)
+}
+
+func (c *grpcClient) GetContext(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.client.Get(ctx, &proto.GetRequest{
+		Key: key,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return resp.Value, nil
+}
+
+func (c *grpcClient) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	stream, err := c.client.List(ctx, &proto.ListRequest{
+		Prefix: prefix,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	// The keys are streamed one at a time, until the plugin closes the stream.
+	var keys []string
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return keys, nil
+		} else if err != nil {
+			return nil, fromStatus(err)
+		}
+		keys = append(keys, resp.Key)
+	}
+}
+
+func (c *grpcClient) DeleteContext(ctx context.Context, key string) error {
+	_, err := c.client.Delete(ctx, &proto.DeleteRequest{
+		Key: key,
+	})
+	return fromStatus(err)
+}
+
+func (c *grpcClient) HasContext(ctx context.Context, key string) (bool, error) {
+	resp, err := c.client.Has(ctx, &proto.HasRequest{
+		Key: key,
+	})
+	if err != nil {
+		return false, fromStatus(err)
+	}
+	return resp.Exists, nil
+}
+
+func (c *grpcClient) Watch(ctx context.Context, prefix string) (<-chan WatchEvent, error) {
+	stream, err := c.client.Watch(ctx, &proto.WatchRequest{
+		Prefix: prefix,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+
+	// The events are streamed until the context is done, which closes the
+	// stream, or the plugin fails.
+	events := make(chan WatchEvent)
+	go func() {
+		defer close(events)
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF || ctx.Err() != nil {
+				return
+			} else if err != nil {
+				select {
+				case events <- WatchEvent{Err: fromStatus(err)}:
+				case <-ctx.Done():
+				}
+				return
+			}
+
+			event := WatchEvent{Type: EventPut, Key: resp.Key}
+			if resp.Type == proto.WatchEventType_DELETE {
+				event.Type = EventDelete
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (c *grpcClient) PutMany(items []KeyValue) error {
+	return c.PutManyContext(context.Background(), items)
+}
+
+func (c *grpcClient) GetMany(keys []string) ([]KeyValue, error) {
+	return c.GetManyContext(context.Background(), keys)
+}
+
+// PutManyContext sends the items in a single request, unless they are larger
+// than the batch size, in which case they are streamed to the plugin in parts
+// of that size, keeping each message below the gRPC message size limit.
+func (c *grpcClient) PutManyContext(ctx context.Context, items []KeyValue) error {
+	parts := splitBatch(items, batchSize)
+	if len(parts) <= 1 {
+		_, err := c.client.PutMany(ctx, &proto.PutManyRequest{
+			Items: toProtoItems(items),
+		})
+		return fromStatus(err)
+	}
+
+	stream, err := c.client.PutManyStream(ctx)
+	if err != nil {
+		return fromStatus(err)
+	}
+	for _, part := range parts {
+		// When the plugin fails the stream, Send returns io.EOF, with the
+		// error of the plugin being returned by CloseAndRecv.
+		err := stream.Send(&proto.PutManyRequest{Items: toProtoItems(part)})
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fromStatus(err)
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return fromStatus(err)
+}
+
+func (c *grpcClient) GetManyContext(ctx context.Context, keys []string) ([]KeyValue, error) {
+	resp, err := c.client.GetMany(ctx, &proto.GetManyRequest{
+		Keys: keys,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
+	}
+	return fromProtoItems(resp.Items), nil
+}
+
+// batchSize is the size of the keys and values sent in each part of a
+// streamed batch, which is well below the default 4 MB gRPC message limit.
+const batchSize = 1 << 20
+
+// splitBatch splits the items into parts with keys and values no larger than
+// the size in total. An item larger than the size is sent on its own.
+func splitBatch(items []KeyValue, size int) [][]KeyValue {
+	var parts [][]KeyValue
+	var part []KeyValue
+	var partSize int
+	for _, item := range items {
+		itemSize := len(item.Key) + len(item.Value)
+		if len(part) > 0 && partSize+itemSize > size {
+			parts = append(parts, part)
+			part, partSize = nil, 0
+		}
+		part = append(part, item)
+		partSize += itemSize
+	}
+	if len(part) > 0 {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+func toProtoItems(items []KeyValue) []*proto.KeyValue {
+	kvs := make([]*proto.KeyValue, len(items))
+	for i, item := range items {
+		kvs[i] = &proto.KeyValue{Key: item.Key, Value: item.Value}
+	}
+	return kvs
+}
+
+func fromProtoItems(kvs []*proto.KeyValue) []KeyValue {
+	items := make([]KeyValue, len(kvs))
+	for i, kv := range kvs {
+		items[i] = KeyValue{Key: kv.Key, Value: kv.Value}
+	}
+	return items
+}
+
+func (c *grpcClient) PutStream(key string, r io.Reader) error {
+	return c.PutStreamContext(context.Background(), key, r)
+}
+
+func (c *grpcClient) GetStream(key string, w io.Writer) error {
+	return c.GetStreamContext(context.Background(), key, w)
+}
+
+// PutStreamContext sends the value to the plugin in chunks as it is read. The
+// stream is cancelled when reading fails, so the plugin does not store the
+// part of the value already sent.
+func (c *grpcClient) PutStreamContext(ctx context.Context, key string, r io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.client.PutStream(ctx)
+	if err != nil {
+		return fromStatus(err)
+	}
+
+	// The key is sent along with the first chunk, which is empty for an
+	// empty value.
+	buf := make([]byte, chunkSize)
+	req := &proto.PutStreamRequest{Key: key}
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+
+		// When the plugin fails the stream, Send returns io.EOF, with the
+		// error of the plugin being returned by CloseAndRecv.
+		req.Chunk = buf[:n]
+		if err := stream.Send(req); err == io.EOF {
+			break
+		} else if err != nil {
+			return fromStatus(err)
+		}
+		if last {
+			break
+		}
+		req = &proto.PutStreamRequest{}
+	}
+	_, err = stream.CloseAndRecv()
+	return fromStatus(err)
+}
+
+// GetStreamContext writes each chunk of the value to w as it is received.
+func (c *grpcClient) GetStreamContext(ctx context.Context, key string, w io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.client.GetStream(ctx, &proto.GetRequest{
+		Key: key,
+	})
+	if err != nil {
+		return fromStatus(err)
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fromStatus(err)
+		}
+		if _, err := w.Write(resp.Chunk); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *grpcClient) History(key string) ([]Revision, error) {
+	return c.HistoryContext(context.Background(), key)
+}
+
+func (c *grpcClient) GetRevision(key string, revision int64) ([]byte, error) {
+	return c.GetRevisionContext(context.Background(), key, revision)
+}
+
+func (c *grpcClient) Rollback(key string, revision int64) error {
+	return c.RollbackContext(context.Background(), key, revision)
+}
+
+func (c *grpcClient) HistoryContext(ctx context.Context, key string) ([]Revision, error) {
+	resp, err := c.client.History(ctx, &proto.HistoryRequest{
 		Key: key,
 	})
 	if err != nil {
-		return nil, err
+		return nil, fromStatus(err)
+	}
+	revisions := make([]Revision, len(resp.Revisions))
+	for i, r := range resp.Revisions {
+		revisions[i] = Revision{Number: r.Number, Time: time.Unix(0, r.Time), Size: r.Size}
+	}
+	return revisions, nil
+}
+
+func (c *grpcClient) GetRevisionContext(ctx context.Context, key string, revision int64) ([]byte, error) {
+	resp, err := c.client.GetRevision(ctx, &proto.RevisionRequest{
+		Key:      key,
+		Revision: revision,
+	})
+	if err != nil {
+		return nil, fromStatus(err)
 	}
 	return resp.Value, nil
 }
 
+func (c *grpcClient) RollbackContext(ctx context.Context, key string, revision int64) error {
+	_, err := c.client.Rollback(ctx, &proto.RevisionRequest{
+		Key:      key,
+		Revision: revision,
+	})
+	return fromStatus(err)
+}
+
+func (c *grpcClient) Txn(txn Txn) (bool, error) {
+	return c.TxnContext(context.Background(), txn)
+}
+
+func (c *grpcClient) TxnContext(ctx context.Context, txn Txn) (bool, error) {
+	req := &proto.TxnRequest{
+		Compares: make([]*proto.Compare, len(txn.Compares)),
+		Ops:      make([]*proto.Op, len(txn.Ops)),
+	}
+	for i, cmp := range txn.Compares {
+		req.Compares[i] = &proto.Compare{Type: proto.CompareType(cmp.Type), Key: cmp.Key, Value: cmp.Value}
+	}
+	for i, op := range txn.Ops {
+		req.Ops[i] = &proto.Op{Type: proto.OpType(op.Type), Key: op.Key, Value: op.Value}
+	}
+
+	resp, err := c.client.Txn(ctx, req)
+	if err != nil {
+		return false, fromStatus(err)
+	}
+	return resp.Succeeded, nil
+}
+
 // grpcServer is the gRPC server that grpcClient talks to.
+//
+// When the Impl is also a ContextKVStore, the request context is passed on
+// to it, so the plugin can stop work once the host is no longer waiting.
+// Otherwise a request is refused should its context already be done.
 type grpcServer struct {
 	proto.UnimplementedKVServer // enable forward-compatibility
 
 	Impl KVStore
 }
 
-func (s *grpcServer) Put(_ context.Context, req *proto.PutRequest) (*proto.Empty, error) {
-	return &proto.Empty{}, s.Impl.Put(req.Key, req.Value)
+// checkContext returns the error of the context when it is done and the Impl
+// is not a ContextKVStore, which could not see the context itself.
+func (s *grpcServer) checkContext(ctx context.Context) error {
+	if _, ok := s.Impl.(ContextKVStore); ok {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// Put puts a key with a TTL using the TTLKVStore methods of the Impl, failing
+// when it does not implement them.
+func (s *grpcServer) Put(ctx context.Context, req *proto.PutRequest) (*proto.Empty, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	if req.TtlMillis < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "ttl must not be negative, given %dms", req.TtlMillis)
+	} else if req.TtlMillis > 0 {
+		ttl := time.Duration(req.TtlMillis) * time.Millisecond
+		return &proto.Empty{}, toStatus(putWithTTL(ctx, s.Impl, req.Key, req.Value, ttl))
+	}
+	if impl, ok := s.Impl.(ContextKVStore); ok {
+		return &proto.Empty{}, toStatus(impl.PutContext(ctx, req.Key, req.Value))
+	}
+	return &proto.Empty{}, toStatus(s.Impl.Put(req.Key, req.Value))
+}
+
+func (s *grpcServer) Get(ctx context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	var v []byte
+	var err error
+	if impl, ok := s.Impl.(ContextKVStore); ok {
+		v, err = impl.GetContext(ctx, req.Key)
+	} else {
+		v, err = s.Impl.Get(req.Key)
+	}
+	return &proto.GetResponse{Value: v}, toStatus(err)
+}
+
+func (s *grpcServer) List(req *proto.ListRequest, stream proto.KV_ListServer) error {
+	if err := s.checkContext(stream.Context()); err != nil {
+		return toStatus(err)
+	}
+	var keys []string
+	var err error
+	if impl, ok := s.Impl.(ContextKVStore); ok {
+		keys, err = impl.ListContext(stream.Context(), req.Prefix)
+	} else {
+		keys, err = s.Impl.List(req.Prefix)
+	}
+	if err != nil {
+		return toStatus(err)
+	}
+
+	for _, key := range keys {
+		if err := stream.Send(&proto.ListResponse{Key: key}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *grpcServer) Delete(ctx context.Context, req *proto.DeleteRequest) (*proto.Empty, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	if impl, ok := s.Impl.(ContextKVStore); ok {
+		return &proto.Empty{}, toStatus(impl.DeleteContext(ctx, req.Key))
+	}
+	return &proto.Empty{}, toStatus(s.Impl.Delete(req.Key))
+}
+
+func (s *grpcServer) Has(ctx context.Context, req *proto.HasRequest) (*proto.HasResponse, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	var v bool
+	var err error
+	if impl, ok := s.Impl.(ContextKVStore); ok {
+		v, err = impl.HasContext(ctx, req.Key)
+	} else {
+		v, err = s.Impl.Has(req.Key)
+	}
+	return &proto.HasResponse{Exists: v}, toStatus(err)
 }
 
-func (s *grpcServer) Get(_ context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
-	v, err := s.Impl.Get(req.Key)
-	return &proto.GetResponse{Value: v}, err
+func (s *grpcServer) Watch(req *proto.WatchRequest, stream proto.KV_WatchServer) error {
+	impl, ok := s.Impl.(Watcher)
+	if !ok {
+		return status.Error(codes.Unimplemented, "the plugin does not support watching keys")
+	}
+
+	// The stream context is done once the host stops watching.
+	events, err := impl.Watch(stream.Context(), req.Prefix)
+	if err != nil {
+		return toStatus(err)
+	}
+	for event := range events {
+		if event.Err != nil {
+			return toStatus(event.Err)
+		}
+
+		resp := &proto.WatchEvent{Type: proto.WatchEventType_PUT, Key: event.Key}
+		if event.Type == EventDelete {
+			resp.Type = proto.WatchEventType_DELETE
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *grpcServer) PutMany(ctx context.Context, req *proto.PutManyRequest) (*proto.Empty, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.Empty{}, toStatus(putMany(ctx, s.Impl, fromProtoItems(req.Items)))
+}
+
+func (s *grpcServer) GetMany(ctx context.Context, req *proto.GetManyRequest) (*proto.GetManyResponse, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	items, err := getMany(ctx, s.Impl, req.Keys)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.GetManyResponse{Items: toProtoItems(items)}, nil
+}
+
+// PutManyStream puts each part of the batch as it is received, so the whole
+// batch is never held in memory.
+func (s *grpcServer) PutManyStream(stream proto.KV_PutManyStreamServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&proto.Empty{})
+		} else if err != nil {
+			return err
+		}
+		if err := s.checkContext(stream.Context()); err != nil {
+			return toStatus(err)
+		}
+		if err := putMany(stream.Context(), s.Impl, fromProtoItems(req.Items)); err != nil {
+			return toStatus(err)
+		}
+	}
+}
+
+// PutStream passes the chunks of the value on to the Impl as they are
+// received, so the value is not held in memory when the Impl is a
+// StreamKVStore.
+func (s *grpcServer) PutStream(stream proto.KV_PutStreamServer) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "no key was sent")
+	} else if err != nil {
+		return err
+	}
+	if err := s.checkContext(stream.Context()); err != nil {
+		return toStatus(err)
+	}
+
+	r := &chunkReader{
+		chunk: req.Chunk,
+		recv: func() ([]byte, error) {
+			req, err := stream.Recv()
+			if err != nil {
+				return nil, err
+			}
+			return req.Chunk, nil
+		},
+	}
+	if err := putStream(stream.Context(), s.Impl, req.Key, r); err != nil {
+		return toStatus(err)
+	}
+	return stream.SendAndClose(&proto.Empty{})
+}
+
+func (s *grpcServer) History(ctx context.Context, req *proto.HistoryRequest) (*proto.HistoryResponse, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	revisions, err := history(ctx, s.Impl, req.Key)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &proto.HistoryResponse{Revisions: make([]*proto.Revision, len(revisions))}
+	for i, r := range revisions {
+		resp.Revisions[i] = &proto.Revision{Number: r.Number, Time: r.Time.UnixNano(), Size: r.Size}
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) GetRevision(ctx context.Context, req *proto.RevisionRequest) (*proto.GetResponse, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	v, err := getRevision(ctx, s.Impl, req.Key, req.Revision)
+	return &proto.GetResponse{Value: v}, toStatus(err)
+}
+
+func (s *grpcServer) Rollback(ctx context.Context, req *proto.RevisionRequest) (*proto.Empty, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.Empty{}, toStatus(rollback(ctx, s.Impl, req.Key, req.Revision))
+}
+
+func (s *grpcServer) Txn(ctx context.Context, req *proto.TxnRequest) (*proto.TxnResponse, error) {
+	if err := s.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	txn := Txn{
+		Compares: make([]Compare, len(req.Compares)),
+		Ops:      make([]Op, len(req.Ops)),
+	}
+	for i, cmp := range req.Compares {
+		txn.Compares[i] = Compare{Type: CompareType(cmp.Type), Key: cmp.Key, Value: cmp.Value}
+	}
+	for i, op := range req.Ops {
+		txn.Ops[i] = Op{Type: OpType(op.Type), Key: op.Key, Value: op.Value}
+	}
+
+	succeeded, err := applyTxn(ctx, s.Impl, txn)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &proto.TxnResponse{Succeeded: succeeded}, nil
+}
+
+// GetStream sends the value written by the Impl in chunks.
+func (s *grpcServer) GetStream(req *proto.GetRequest, stream proto.KV_GetStreamServer) error {
+	if err := s.checkContext(stream.Context()); err != nil {
+		return toStatus(err)
+	}
+	w := bufio.NewWriterSize(&chunkWriter{
+		send: func(chunk []byte) error {
+			return stream.Send(&proto.GetStreamResponse{Chunk: chunk})
+		},
+	}, chunkSize)
+	if err := getStream(stream.Context(), s.Impl, req.Key, w); err != nil {
+		return toStatus(err)
+	}
+	return w.Flush()
 }
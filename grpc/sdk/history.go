@@ -0,0 +1,114 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+
+	"github.com/mrcook/go-plugin-examples/storage"
+)
+
+// Revision describes one of the values a key has been put with. It is the
+// same type as returned by the storage.FileStore, so file-backed plugins can
+// return its history as is.
+type Revision = storage.Revision
+
+// HistoryKVStore is implemented by KVStore plugins that keep the earlier
+// values of each key, as numbered revisions, up to a number of revisions set
+// by the plugin, e.g. using the storage.RevisionsEnv environment variable.
+//
+// Plugins need not implement it: the history requests then fail.
+type HistoryKVStore interface {
+	// History returns the revisions kept for the key, oldest first, with the
+	// last being the current value.
+	History(key string) ([]Revision, error)
+
+	// GetRevision returns the value of the key at the revision, or an
+	// ErrNotFound error when the revision is no longer kept.
+	GetRevision(key string, revision int64) ([]byte, error)
+
+	// Rollback puts the value of the key at the revision again, as a new
+	// revision, so the revisions since are not lost.
+	Rollback(key string, revision int64) error
+}
+
+// ContextHistoryKVStore is the context-aware variant of the HistoryKVStore
+// interface.
+//
+// The clients dispensed for the kv_grpc and kv_netrpc plugins implement this
+// interface.
+type ContextHistoryKVStore interface {
+	HistoryContext(ctx context.Context, key string) ([]Revision, error)
+	GetRevisionContext(ctx context.Context, key string, revision int64) ([]byte, error)
+	RollbackContext(ctx context.Context, key string, revision int64) error
+}
+
+// errHistoryUnsupported is returned for the history requests made to a store
+// that does not keep the history of keys.
+var errHistoryUnsupported = NewError(Internal, "the plugin does not keep the history of keys")
+
+// History returns the revisions kept for the key, oldest first. The store must
+// be a ContextHistoryKVStore.
+func History(ctx context.Context, kv ContextKVStore, key string) ([]Revision, error) {
+	history, ok := kv.(ContextHistoryKVStore)
+	if !ok {
+		return nil, errHistoryUnsupported
+	}
+	return history.HistoryContext(ctx, key)
+}
+
+// GetRevision returns the value of the key at the revision. The store must be
+// a ContextHistoryKVStore.
+func GetRevision(ctx context.Context, kv ContextKVStore, key string, revision int64) ([]byte, error) {
+	history, ok := kv.(ContextHistoryKVStore)
+	if !ok {
+		return nil, errHistoryUnsupported
+	}
+	return history.GetRevisionContext(ctx, key, revision)
+}
+
+// Rollback puts the value of the key at the revision again, as a new revision.
+// The store must be a ContextHistoryKVStore.
+func Rollback(ctx context.Context, kv ContextKVStore, key string, revision int64) error {
+	history, ok := kv.(ContextHistoryKVStore)
+	if !ok {
+		return errHistoryUnsupported
+	}
+	return history.RollbackContext(ctx, key, revision)
+}
+
+// history is used by the servers to return the revisions of a key using the
+// plugin's Impl, calling the most capable of the interfaces it implements.
+func history(ctx context.Context, impl KVStore, key string) ([]Revision, error) {
+	switch impl := impl.(type) {
+	case ContextHistoryKVStore:
+		return impl.HistoryContext(ctx, key)
+	case HistoryKVStore:
+		return impl.History(key)
+	}
+	return nil, errHistoryUnsupported
+}
+
+// getRevision is used by the servers to return a revision of a key using the
+// plugin's Impl.
+func getRevision(ctx context.Context, impl KVStore, key string, revision int64) ([]byte, error) {
+	switch impl := impl.(type) {
+	case ContextHistoryKVStore:
+		return impl.GetRevisionContext(ctx, key, revision)
+	case HistoryKVStore:
+		return impl.GetRevision(key, revision)
+	}
+	return nil, errHistoryUnsupported
+}
+
+// rollback is used by the servers to roll a key back using the plugin's Impl.
+func rollback(ctx context.Context, impl KVStore, key string, revision int64) error {
+	switch impl := impl.(type) {
+	case ContextHistoryKVStore:
+		return impl.RollbackContext(ctx, key, revision)
+	case HistoryKVStore:
+		return impl.Rollback(key, revision)
+	}
+	return errHistoryUnsupported
+}
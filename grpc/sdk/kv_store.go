@@ -24,6 +24,32 @@ import (
 type KVStore interface {
 	Put(key string, value []byte) error
 	Get(key string) ([]byte, error)
+
+	// List returns all the keys starting with the prefix. An empty prefix
+	// returns every key in the store.
+	List(prefix string) ([]string, error)
+
+	// Delete removes the key from the store. Deleting a key that does not
+	// exist is not an error.
+	Delete(key string) error
+
+	// Has reports whether the key exists in the store.
+	Has(key string) (bool, error)
+}
+
+// ContextKVStore is the context-aware variant of the KVStore interface.
+//
+// The clients dispensed to host applications implement this interface, so
+// that a deadline or cancellation can be set on each request. Plugins may
+// also implement it, in which case the context of the incoming request,
+// including any deadline set by the host, is passed to these methods
+// instead of those of the KVStore.
+type ContextKVStore interface {
+	PutContext(ctx context.Context, key string, value []byte) error
+	GetContext(ctx context.Context, key string) ([]byte, error)
+	ListContext(ctx context.Context, prefix string) ([]string, error)
+	DeleteContext(ctx context.Context, key string) error
+	HasContext(ctx context.Context, key string) (bool, error)
 }
 
 // These constants are an important variables.
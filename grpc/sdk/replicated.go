@@ -0,0 +1,305 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ReplicationConfig defines the quorum sizes of a ReplicatedKVStore.
+type ReplicationConfig struct {
+	// WriteQuorum is how many replicas must accept a Put or Delete for it to
+	// succeed. Writes are always sent to every replica. Defaults to all the
+	// replicas.
+	WriteQuorum int
+
+	// ReadQuorum is how many replicas must respond to a Get, Has, or List for
+	// it to succeed. Defaults to a majority of the replicas.
+	ReadQuorum int
+
+	// Normalize returns the value as it was written, for plugins that change
+	// the values they store, e.g. by adding a note. Values read from the
+	// replicas are normalized before being compared, and before being written
+	// back by read-repair. Values are used unchanged when nil.
+	Normalize func(value []byte) []byte
+}
+
+// ReplicatedKVStore is a KVStore for host applications, which stores every
+// value in several plugins, such that the other plugins can be used when one
+// of them fails.
+//
+// Writes are sent to all the replicas, succeeding once WriteQuorum of them
+// have accepted it. Reads are sent to all the replicas, using the responses
+// of the first ReadQuorum of them. When those replicas disagree, the value
+// returned by most of them is used, with a tie going to the replica given
+// first, and the other replicas are repaired by writing that value to them.
+type ReplicatedKVStore struct {
+	replicas    []ContextKVStore
+	writeQuorum int
+	readQuorum  int
+	normalize   func(value []byte) []byte
+}
+
+// NewReplicatedKVStore returns a ReplicatedKVStore for the given replicas,
+// such as the clients dispensed for each plugin, or a SupervisedKVStore.
+func NewReplicatedKVStore(replicas []ContextKVStore, config ReplicationConfig) (*ReplicatedKVStore, error) {
+	if len(replicas) == 0 {
+		return nil, errors.New("replicated store needs at least one replica")
+	}
+	if config.WriteQuorum == 0 {
+		config.WriteQuorum = len(replicas)
+	}
+	if config.ReadQuorum == 0 {
+		config.ReadQuorum = len(replicas)/2 + 1
+	}
+	if config.WriteQuorum < 1 || config.WriteQuorum > len(replicas) {
+		return nil, fmt.Errorf("write quorum must be between 1 and %d, given %d", len(replicas), config.WriteQuorum)
+	}
+	if config.ReadQuorum < 1 || config.ReadQuorum > len(replicas) {
+		return nil, fmt.Errorf("read quorum must be between 1 and %d, given %d", len(replicas), config.ReadQuorum)
+	}
+	if config.Normalize == nil {
+		config.Normalize = func(value []byte) []byte { return value }
+	}
+
+	return &ReplicatedKVStore{
+		replicas:    replicas,
+		writeQuorum: config.WriteQuorum,
+		readQuorum:  config.ReadQuorum,
+		normalize:   config.Normalize,
+	}, nil
+}
+
+func (s *ReplicatedKVStore) Put(key string, value []byte) error {
+	return s.PutContext(context.Background(), key, value)
+}
+
+func (s *ReplicatedKVStore) Get(key string) ([]byte, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+func (s *ReplicatedKVStore) List(prefix string) ([]string, error) {
+	return s.ListContext(context.Background(), prefix)
+}
+
+func (s *ReplicatedKVStore) Delete(key string) error {
+	return s.DeleteContext(context.Background(), key)
+}
+
+func (s *ReplicatedKVStore) Has(key string) (bool, error) {
+	return s.HasContext(context.Background(), key)
+}
+
+func (s *ReplicatedKVStore) PutContext(ctx context.Context, key string, value []byte) error {
+	return s.write(func(kv ContextKVStore) error {
+		return kv.PutContext(ctx, key, value)
+	})
+}
+
+func (s *ReplicatedKVStore) DeleteContext(ctx context.Context, key string) error {
+	return s.write(func(kv ContextKVStore) error {
+		return kv.DeleteContext(ctx, key)
+	})
+}
+
+// GetContext returns the value agreed by the replicas, repairing any replica
+// that disagrees. A key missing from most replicas is not found, and is
+// deleted from the other replicas.
+func (s *ReplicatedKVStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	responses, err := s.read(func(kv ContextKVStore) (interface{}, error) {
+		value, err := kv.GetContext(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			// A missing key is a valid response, which is also compared.
+			return replicaValue{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return replicaValue{value: s.normalize(value), found: true}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	agreed, stale := s.agree(responses)
+	s.repair(ctx, key, agreed, stale)
+
+	if !agreed.found {
+		return nil, NewError(NotFound, "key %q not found", key)
+	}
+	return agreed.value, nil
+}
+
+// HasContext reports whether the key exists, in the same way as GetContext.
+func (s *ReplicatedKVStore) HasContext(ctx context.Context, key string) (bool, error) {
+	_, err := s.GetContext(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ListContext returns the keys found in any of the replicas that responded.
+// Keys that are missing from some replicas are repaired when next read.
+func (s *ReplicatedKVStore) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	responses, err := s.read(func(kv ContextKVStore) (interface{}, error) {
+		return kv.ListContext(ctx, prefix)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, r := range responses {
+		for _, key := range r.result.([]string) {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// replicaValue is the value read from a replica, or a missing key.
+type replicaValue struct {
+	value []byte
+	found bool
+}
+
+// equal reports whether two replicas agree on the value.
+func (v replicaValue) equal(other replicaValue) bool {
+	return v.found == other.found && bytes.Equal(v.value, other.value)
+}
+
+// replicaResponse is the response of a single replica to a read.
+type replicaResponse struct {
+	replica int // index of the replica
+	result  interface{}
+	err     error
+}
+
+// write sends the write to every replica, returning an error when fewer than
+// the write quorum accepted it.
+func (s *ReplicatedKVStore) write(fn func(kv ContextKVStore) error) error {
+	errs := make([]error, len(s.replicas))
+	var wg sync.WaitGroup
+	for i, kv := range s.replicas {
+		wg.Add(1)
+		go func(i int, kv ContextKVStore) {
+			defer wg.Done()
+			errs[i] = fn(kv)
+		}(i, kv)
+	}
+	wg.Wait()
+
+	var accepted int
+	var firstErr error
+	for _, err := range errs {
+		if err == nil {
+			accepted++
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if accepted < s.writeQuorum {
+		return fmt.Errorf("write quorum not reached, %d of %d replicas accepted the write: %w", accepted, s.writeQuorum, firstErr)
+	}
+	return nil
+}
+
+// read sends the read to every replica, returning the responses of the first
+// replicas to reach the read quorum, sorted by replica. An error is returned
+// when too many replicas fail for the quorum to be reached.
+func (s *ReplicatedKVStore) read(fn func(kv ContextKVStore) (interface{}, error)) ([]replicaResponse, error) {
+	// The channel is buffered so the replicas responding after the quorum is
+	// reached do not block.
+	ch := make(chan replicaResponse, len(s.replicas))
+	for i, kv := range s.replicas {
+		go func(i int, kv ContextKVStore) {
+			result, err := fn(kv)
+			ch <- replicaResponse{replica: i, result: result, err: err}
+		}(i, kv)
+	}
+
+	var responses []replicaResponse
+	var failed int
+	var firstErr error
+	for range s.replicas {
+		r := <-ch
+		if r.err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = r.err
+			}
+		} else {
+			responses = append(responses, r)
+		}
+
+		if len(responses) == s.readQuorum {
+			sort.Slice(responses, func(i, j int) bool { return responses[i].replica < responses[j].replica })
+			return responses, nil
+		}
+		if len(s.replicas)-failed < s.readQuorum {
+			break
+		}
+	}
+	return nil, fmt.Errorf("read quorum not reached, %d of %d replicas responded: %w", len(responses), s.readQuorum, firstErr)
+}
+
+// agree returns the value returned by most replicas, along with the replicas
+// that returned another value.
+func (s *ReplicatedKVStore) agree(responses []replicaResponse) (replicaValue, []int) {
+	// The responses are sorted by replica, so on a tie the first value
+	// counted, from the replica given first, is used.
+	var best replicaValue
+	bestVotes := 0
+	for _, r := range responses {
+		value := r.result.(replicaValue)
+		votes := 0
+		for _, other := range responses {
+			if value.equal(other.result.(replicaValue)) {
+				votes++
+			}
+		}
+		if votes > bestVotes {
+			best, bestVotes = value, votes
+		}
+	}
+
+	var stale []int
+	for _, r := range responses {
+		if !best.equal(r.result.(replicaValue)) {
+			stale = append(stale, r.replica)
+		}
+	}
+	return best, stale
+}
+
+// repair writes the value to the stale replicas, or deletes the key when it
+// is missing from most replicas. Repairs are best effort: a replica that
+// fails is repaired on a later read.
+func (s *ReplicatedKVStore) repair(ctx context.Context, key string, v replicaValue, stale []int) {
+	var wg sync.WaitGroup
+	for _, i := range stale {
+		wg.Add(1)
+		go func(kv ContextKVStore) {
+			defer wg.Done()
+			if v.found {
+				_ = kv.PutContext(ctx, key, v.value)
+			} else {
+				_ = kv.DeleteContext(ctx, key)
+			}
+		}(s.replicas[i])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,249 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryKV is an in-memory ContextKVStore, which fails every call when down.
+type memoryKV struct {
+	mu     sync.Mutex
+	values map[string]string
+	down   bool
+}
+
+func newMemoryKV(values map[string]string) *memoryKV {
+	kv := &memoryKV{values: make(map[string]string)}
+	for k, v := range values {
+		kv.values[k] = v
+	}
+	return kv
+}
+
+var errReplicaDown = errors.New("replica is down")
+
+func (m *memoryKV) PutContext(_ context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return errReplicaDown
+	}
+	m.values[key] = string(value)
+	return nil
+}
+
+func (m *memoryKV) GetContext(_ context.Context, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return nil, errReplicaDown
+	}
+	value, ok := m.values[key]
+	if !ok {
+		return nil, NewError(NotFound, "key %q not found", key)
+	}
+	return []byte(value), nil
+}
+
+func (m *memoryKV) ListContext(_ context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return nil, errReplicaDown
+	}
+	var keys []string
+	for key := range m.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *memoryKV) DeleteContext(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return errReplicaDown
+	}
+	delete(m.values, key)
+	return nil
+}
+
+func (m *memoryKV) HasContext(ctx context.Context, key string) (bool, error) {
+	_, err := m.GetContext(ctx, key)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m *memoryKV) value(key string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	value, ok := m.values[key]
+	return value, ok
+}
+
+func newReplicatedKV(t *testing.T, config ReplicationConfig, replicas ...*memoryKV) *ReplicatedKVStore {
+	t.Helper()
+	kvs := make([]ContextKVStore, len(replicas))
+	for i, r := range replicas {
+		kvs[i] = r
+	}
+	kv, err := NewReplicatedKVStore(kvs, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return kv
+}
+
+func TestNewReplicatedKVStoreQuorums(t *testing.T) {
+	replicas := []ContextKVStore{newMemoryKV(nil), newMemoryKV(nil), newMemoryKV(nil)}
+	tests := []struct {
+		name    string
+		config  ReplicationConfig
+		wantErr bool
+	}{
+		{"defaults", ReplicationConfig{}, false},
+		{"write quorum of one", ReplicationConfig{WriteQuorum: 1}, false},
+		{"write quorum too large", ReplicationConfig{WriteQuorum: 4}, true},
+		{"read quorum too large", ReplicationConfig{ReadQuorum: 4}, true},
+		{"negative read quorum", ReplicationConfig{ReadQuorum: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewReplicatedKVStore(replicas, tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewReplicatedKVStore() error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewReplicatedKVStore(nil, ReplicationConfig{}); err == nil {
+		t.Error("NewReplicatedKVStore(nil) error = nil, want an error")
+	}
+}
+
+func TestReplicatedWriteQuorum(t *testing.T) {
+	a, b, c := newMemoryKV(nil), newMemoryKV(nil), newMemoryKV(nil)
+	c.down = true
+
+	kv := newReplicatedKV(t, ReplicationConfig{WriteQuorum: 2}, a, b, c)
+	if err := kv.PutContext(context.Background(), "k", []byte("v")); err != nil {
+		t.Errorf("Put() with 2 of 3 replicas error = %v", err)
+	}
+
+	kv = newReplicatedKV(t, ReplicationConfig{}, a, b, c)
+	err := kv.PutContext(context.Background(), "k", []byte("v"))
+	if err == nil || !errors.Is(err, errReplicaDown) {
+		t.Errorf("Put() with the default write quorum error = %v, want the replica error", err)
+	}
+}
+
+func TestReplicatedGetRepairs(t *testing.T) {
+	tests := []struct {
+		name      string
+		replicas  []map[string]string
+		want      string
+		wantFound bool
+	}{
+		{
+			name:      "stale replica",
+			replicas:  []map[string]string{{"k": "new"}, {"k": "old"}, {"k": "new"}},
+			want:      "new",
+			wantFound: true,
+		},
+		{
+			name:      "missing from one replica",
+			replicas:  []map[string]string{{}, {"k": "v"}, {"k": "v"}},
+			want:      "v",
+			wantFound: true,
+		},
+		{
+			name:     "missing from most replicas",
+			replicas: []map[string]string{{}, {"k": "v"}, {}},
+		},
+		{
+			name:      "tie goes to the first replica",
+			replicas:  []map[string]string{{"k": "first"}, {"k": "second"}},
+			want:      "first",
+			wantFound: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var replicas []*memoryKV
+			for _, values := range tt.replicas {
+				replicas = append(replicas, newMemoryKV(values))
+			}
+			kv := newReplicatedKV(t, ReplicationConfig{ReadQuorum: len(replicas)}, replicas...)
+
+			value, err := kv.GetContext(context.Background(), "k")
+			if !tt.wantFound {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("Get() error = %v, want not found", err)
+				}
+			} else if err != nil || string(value) != tt.want {
+				t.Errorf("Get() = %q, %v, want %q", value, err, tt.want)
+			}
+
+			// Every replica now agrees on the value.
+			for i, r := range replicas {
+				got, found := r.value("k")
+				if found != tt.wantFound || got != tt.want {
+					t.Errorf("replica %d = %q, %v, want %q, %v", i, got, found, tt.want, tt.wantFound)
+				}
+			}
+		})
+	}
+}
+
+func TestReplicatedNormalize(t *testing.T) {
+	a := newMemoryKV(map[string]string{"k": "v"})
+	b := newMemoryKV(map[string]string{"k": "v\n\nWritten from b"})
+	kv := newReplicatedKV(t, ReplicationConfig{
+		ReadQuorum: 2,
+		Normalize: func(value []byte) []byte {
+			if i := strings.Index(string(value), "\n\nWritten from "); i >= 0 {
+				return value[:i]
+			}
+			return value
+		},
+	}, a, b)
+
+	value, err := kv.GetContext(context.Background(), "k")
+	if err != nil || string(value) != "v" {
+		t.Errorf("Get() = %q, %v, want %q", value, err, "v")
+	}
+	if got, _ := b.value("k"); got != "v\n\nWritten from b" {
+		t.Errorf("replica b = %q, want it left unrepaired", got)
+	}
+}
+
+func TestReplicatedReadQuorum(t *testing.T) {
+	a := newMemoryKV(map[string]string{"a": "1"})
+	b := newMemoryKV(map[string]string{"b": "2"})
+	c := newMemoryKV(map[string]string{"c": "3"})
+	c.down = true
+
+	kv := newReplicatedKV(t, ReplicationConfig{ReadQuorum: 2}, a, b, c)
+	keys, err := kv.ListContext(context.Background(), "")
+	if err != nil || !reflect.DeepEqual(keys, []string{"a", "b"}) {
+		t.Errorf("List() = %v, %v, want [a b]", keys, err)
+	}
+
+	b.down = true
+	if _, err := kv.GetContext(context.Background(), "a"); !errors.Is(err, errReplicaDown) {
+		t.Errorf("Get() with 1 of 2 replicas error = %v, want the replica error", err)
+	}
+}
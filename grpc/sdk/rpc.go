@@ -5,15 +5,42 @@
 package sdk
 
 import (
+	"context"
 	"net/rpc"
+	"reflect"
+	"time"
 )
 
 // rpcClient is an implementation of KVStore that talks over RPC.
+//
+// It also implements ContextKVStore, however net/rpc has no way to send a
+// deadline to the plugin, or to cancel a request, so the client only stops
+// waiting for the response once the context is done.
 type rpcClient struct {
 	client *rpc.Client
 }
 
 func (m *rpcClient) Put(key string, value []byte) error {
+	return m.PutContext(context.Background(), key, value)
+}
+
+func (m *rpcClient) Get(key string) ([]byte, error) {
+	return m.GetContext(context.Background(), key)
+}
+
+func (m *rpcClient) List(prefix string) ([]string, error) {
+	return m.ListContext(context.Background(), prefix)
+}
+
+func (m *rpcClient) Delete(key string) error {
+	return m.DeleteContext(context.Background(), key)
+}
+
+func (m *rpcClient) Has(key string) (bool, error) {
+	return m.HasContext(context.Background(), key)
+}
+
+func (m *rpcClient) PutContext(ctx context.Context, key string, value []byte) error {
 	// We don't expect a response, so we can just use interface{}
 	var resp interface{}
 
@@ -21,23 +48,165 @@ func (m *rpcClient) Put(key string, value []byte) error {
 	//
 	// `Plugin`: a go-plugin hardcoded value
 	// `Put` the method as defined on the KVStore plugin interface
-	return m.client.Call(
+	return m.call(ctx,
 		"Plugin.Put",
 		map[string]interface{}{"key": key, "value": value},
 		&resp,
 	)
 }
 
-func (m *rpcClient) Get(key string) ([]byte, error) {
+func (m *rpcClient) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return m.PutWithTTLContext(context.Background(), key, value, ttl)
+}
+
+func (m *rpcClient) PutWithTTLContext(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	millis, err := ttlMillis(ttl)
+	if err != nil {
+		return err
+	}
+
+	var resp interface{}
+
+	// The TTL is sent in milliseconds, as for gRPC.
+	return m.call(ctx,
+		"Plugin.PutWithTTL",
+		map[string]interface{}{"key": key, "value": value, "ttl": millis},
+		&resp,
+	)
+}
+
+func (m *rpcClient) GetContext(ctx context.Context, key string) ([]byte, error) {
 	var resp []byte
 
 	// `Plugin`: a go-plugin hardcoded value
 	// `Get` the method as defined on the KVStore plugin interface
-	err := m.client.Call("Plugin.Get", key, &resp)
+	err := m.call(ctx, "Plugin.Get", key, &resp)
+
+	return resp, err
+}
+
+func (m *rpcClient) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	var resp []string
+
+	// net/rpc does not support streaming, so all keys are returned at once.
+	err := m.call(ctx, "Plugin.List", prefix, &resp)
+
+	return resp, err
+}
+
+func (m *rpcClient) DeleteContext(ctx context.Context, key string) error {
+	// We don't expect a response, so we can just use interface{}
+	var resp interface{}
+
+	return m.call(ctx, "Plugin.Delete", key, &resp)
+}
+
+func (m *rpcClient) HasContext(ctx context.Context, key string) (bool, error) {
+	var resp bool
+
+	err := m.call(ctx, "Plugin.Has", key, &resp)
+
+	return resp, err
+}
+
+func (m *rpcClient) PutMany(items []KeyValue) error {
+	return m.PutManyContext(context.Background(), items)
+}
+
+func (m *rpcClient) GetMany(keys []string) ([]KeyValue, error) {
+	return m.GetManyContext(context.Background(), keys)
+}
+
+// PutManyContext sends every item in a single request, as net/rpc has no
+// streaming support.
+func (m *rpcClient) PutManyContext(ctx context.Context, items []KeyValue) error {
+	var resp interface{}
+
+	return m.call(ctx, "Plugin.PutMany", items, &resp)
+}
+
+func (m *rpcClient) GetManyContext(ctx context.Context, keys []string) ([]KeyValue, error) {
+	var resp []KeyValue
+
+	err := m.call(ctx, "Plugin.GetMany", keys, &resp)
+
+	return resp, err
+}
+
+func (m *rpcClient) History(key string) ([]Revision, error) {
+	return m.HistoryContext(context.Background(), key)
+}
+
+func (m *rpcClient) GetRevision(key string, revision int64) ([]byte, error) {
+	return m.GetRevisionContext(context.Background(), key, revision)
+}
+
+func (m *rpcClient) Rollback(key string, revision int64) error {
+	return m.RollbackContext(context.Background(), key, revision)
+}
+
+func (m *rpcClient) HistoryContext(ctx context.Context, key string) ([]Revision, error) {
+	var resp []Revision
+
+	err := m.call(ctx, "Plugin.History", key, &resp)
+
+	return resp, err
+}
+
+func (m *rpcClient) GetRevisionContext(ctx context.Context, key string, revision int64) ([]byte, error) {
+	var resp []byte
+
+	err := m.call(ctx,
+		"Plugin.GetRevision",
+		map[string]interface{}{"key": key, "revision": revision},
+		&resp,
+	)
+
+	return resp, err
+}
+
+func (m *rpcClient) RollbackContext(ctx context.Context, key string, revision int64) error {
+	var resp interface{}
+
+	return m.call(ctx,
+		"Plugin.Rollback",
+		map[string]interface{}{"key": key, "revision": revision},
+		&resp,
+	)
+}
+
+func (m *rpcClient) Txn(txn Txn) (bool, error) {
+	return m.TxnContext(context.Background(), txn)
+}
+
+func (m *rpcClient) TxnContext(ctx context.Context, txn Txn) (bool, error) {
+	var resp bool
+
+	err := m.call(ctx, "Plugin.Txn", txn, &resp)
 
 	return resp, err
 }
 
+// call makes the RPC request, returning early if the context is done before
+// the plugin responds. The response is decoded into a value of its own, only
+// copied to reply once the call is done, as net/rpc may still be decoding the
+// response after returning early.
+func (m *rpcClient) call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	resp := reflect.New(reflect.TypeOf(reply).Elem())
+	call := m.client.Go(method, args, resp.Interface(), nil)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.Done:
+		if call.Error != nil {
+			return fromRPCError(call.Error)
+		}
+		reflect.ValueOf(reply).Elem().Set(resp.Elem())
+		return nil
+	}
+}
+
 // rpcServer is the RPC server that rpcClient talks to, conforming to
 // the requirements of net/rpc
 type rpcServer struct {
@@ -45,11 +214,64 @@ type rpcServer struct {
 }
 
 func (m *rpcServer) Put(args map[string]interface{}, resp *interface{}) error {
-	return m.Impl.Put(args["key"].(string), args["value"].([]byte))
+	return toRPCError(m.Impl.Put(args["key"].(string), args["value"].([]byte)))
+}
+
+func (m *rpcServer) PutWithTTL(args map[string]interface{}, resp *interface{}) error {
+	ttl := time.Duration(args["ttl"].(int64)) * time.Millisecond
+	return toRPCError(putWithTTL(context.Background(), m.Impl, args["key"].(string), args["value"].([]byte), ttl))
 }
 
 func (m *rpcServer) Get(key string, resp *[]byte) error {
 	v, err := m.Impl.Get(key)
 	*resp = v
-	return err
+	return toRPCError(err)
+}
+
+func (m *rpcServer) List(prefix string, resp *[]string) error {
+	v, err := m.Impl.List(prefix)
+	*resp = v
+	return toRPCError(err)
+}
+
+func (m *rpcServer) Delete(key string, resp *interface{}) error {
+	return toRPCError(m.Impl.Delete(key))
+}
+
+func (m *rpcServer) Has(key string, resp *bool) error {
+	v, err := m.Impl.Has(key)
+	*resp = v
+	return toRPCError(err)
+}
+
+func (m *rpcServer) PutMany(items []KeyValue, resp *interface{}) error {
+	return toRPCError(putMany(context.Background(), m.Impl, items))
+}
+
+func (m *rpcServer) GetMany(keys []string, resp *[]KeyValue) error {
+	v, err := getMany(context.Background(), m.Impl, keys)
+	*resp = v
+	return toRPCError(err)
+}
+
+func (m *rpcServer) History(key string, resp *[]Revision) error {
+	v, err := history(context.Background(), m.Impl, key)
+	*resp = v
+	return toRPCError(err)
+}
+
+func (m *rpcServer) GetRevision(args map[string]interface{}, resp *[]byte) error {
+	v, err := getRevision(context.Background(), m.Impl, args["key"].(string), args["revision"].(int64))
+	*resp = v
+	return toRPCError(err)
+}
+
+func (m *rpcServer) Rollback(args map[string]interface{}, resp *interface{}) error {
+	return toRPCError(rollback(context.Background(), m.Impl, args["key"].(string), args["revision"].(int64)))
+}
+
+func (m *rpcServer) Txn(txn Txn, resp *bool) error {
+	v, err := applyTxn(context.Background(), m.Impl, txn)
+	*resp = v
+	return toRPCError(err)
 }
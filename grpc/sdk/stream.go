@@ -0,0 +1,148 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"io"
+)
+
+// StreamKVStore is implemented by KVStore plugins that can store values of
+// any size, without holding the whole value in memory.
+//
+// Plugins need not implement it: the sdk then reads the whole value, and
+// calls Put, or calls Get, and writes the value it returns.
+type StreamKVStore interface {
+	// PutStream stores the value read from r, until r returns io.EOF. When
+	// reading fails, the value must not be stored, e.g. by writing it to a
+	// temporary file, which is only renamed once the whole value is read.
+	PutStream(key string, r io.Reader) error
+
+	// GetStream writes the value of the key to w.
+	GetStream(key string, w io.Writer) error
+}
+
+// ContextStreamKVStore is the context-aware variant of the StreamKVStore
+// interface.
+//
+// The client dispensed for the kv_grpc plugin implements this interface,
+// sending the value to and from the plugin in chunks, so values larger than
+// the gRPC message size limit can be stored. net/rpc has no streaming support,
+// so the client for the kv_netrpc plugin does not implement it.
+type ContextStreamKVStore interface {
+	PutStreamContext(ctx context.Context, key string, r io.Reader) error
+	GetStreamContext(ctx context.Context, key string, w io.Writer) error
+}
+
+// PutStream stores the value read from r, streaming it to the plugin when the
+// store is a ContextStreamKVStore, otherwise the whole value is read, and put.
+func PutStream(ctx context.Context, kv ContextKVStore, key string, r io.Reader) error {
+	if stream, ok := kv.(ContextStreamKVStore); ok {
+		return stream.PutStreamContext(ctx, key, r)
+	}
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return kv.PutContext(ctx, key, value)
+}
+
+// GetStream writes the value of the key to w, streaming it from the plugin
+// when the store is a ContextStreamKVStore, otherwise the whole value is got,
+// then written.
+//
+// When the stream fails, part of the value may have been written to w.
+func GetStream(ctx context.Context, kv ContextKVStore, key string, w io.Writer) error {
+	if stream, ok := kv.(ContextStreamKVStore); ok {
+		return stream.GetStreamContext(ctx, key, w)
+	}
+	value, err := kv.GetContext(ctx, key)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(value)
+	return err
+}
+
+// putStream is used by the server to store a streamed value using the
+// plugin's Impl, calling the most capable of the interfaces it implements.
+func putStream(ctx context.Context, impl KVStore, key string, r io.Reader) error {
+	switch impl := impl.(type) {
+	case ContextStreamKVStore:
+		return impl.PutStreamContext(ctx, key, r)
+	case StreamKVStore:
+		return impl.PutStream(key, r)
+	case ContextKVStore:
+		return PutStream(ctx, impl, key, r)
+	}
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return impl.Put(key, value)
+}
+
+// getStream is used by the server to write a value to the stream using the
+// plugin's Impl, calling the most capable of the interfaces it implements.
+func getStream(ctx context.Context, impl KVStore, key string, w io.Writer) error {
+	switch impl := impl.(type) {
+	case ContextStreamKVStore:
+		return impl.GetStreamContext(ctx, key, w)
+	case StreamKVStore:
+		return impl.GetStream(key, w)
+	case ContextKVStore:
+		return GetStream(ctx, impl, key, w)
+	}
+	value, err := impl.Get(key)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(value)
+	return err
+}
+
+// chunkSize is the size of the chunks a streamed value is sent in.
+const chunkSize = 64 << 10
+
+// chunkReader is an io.Reader of the chunks received from a stream, with
+// recv returning io.EOF once the stream is closed.
+type chunkReader struct {
+	recv  func() ([]byte, error)
+	chunk []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		chunk, err := r.recv()
+		if err != nil {
+			return 0, err
+		}
+		r.chunk = chunk
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// chunkWriter is an io.Writer sending the data written to a stream, in
+// chunks no larger than the chunkSize.
+type chunkWriter struct {
+	send func(chunk []byte) error
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > chunkSize {
+			chunk = chunk[:chunkSize]
+		}
+		if err := w.send(chunk); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/mrcook/go-plugin-examples/supervisor"
+)
+
+// SupervisedKVStore is a KVStore for long-running host applications, which
+// makes its calls to a plugin kept running by a supervisor.Supervisor.
+//
+// When the plugin crashes it is restarted, and the Get, GetMany, List, Has,
+// Delete, History, and GetRevision calls are retried, as repeating them has no
+// further effect. Put, PutWithTTL, PutMany, Rollback, and Txn are not retried:
+// the values may have been written before the plugin exited, so the caller
+// decides whether to write them again. The next call is made to the restarted
+// plugin.
+//
+// The Supervisor must dispense a ContextKVStore, such as the clients for the
+// kv_grpc and kv_netrpc plugins.
+type SupervisedKVStore struct {
+	supervisor *supervisor.Supervisor
+}
+
+// NewSupervisedKVStore returns a SupervisedKVStore using the plugin of the
+// given Supervisor.
+func NewSupervisedKVStore(s *supervisor.Supervisor) *SupervisedKVStore {
+	return &SupervisedKVStore{supervisor: s}
+}
+
+// Restarts returns how many times the plugin has been restarted.
+func (s *SupervisedKVStore) Restarts() int {
+	return s.supervisor.Restarts()
+}
+
+func (s *SupervisedKVStore) Put(key string, value []byte) error {
+	return s.PutContext(context.Background(), key, value)
+}
+
+func (s *SupervisedKVStore) Get(key string) ([]byte, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+func (s *SupervisedKVStore) List(prefix string) ([]string, error) {
+	return s.ListContext(context.Background(), prefix)
+}
+
+func (s *SupervisedKVStore) Delete(key string) error {
+	return s.DeleteContext(context.Background(), key)
+}
+
+func (s *SupervisedKVStore) Has(key string) (bool, error) {
+	return s.HasContext(context.Background(), key)
+}
+
+func (s *SupervisedKVStore) PutContext(ctx context.Context, key string, value []byte) error {
+	raw, err := s.supervisor.Dispense(ctx)
+	if err != nil {
+		return err
+	}
+	return raw.(ContextKVStore).PutContext(ctx, key, value)
+}
+
+func (s *SupervisedKVStore) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return s.PutWithTTLContext(context.Background(), key, value, ttl)
+}
+
+func (s *SupervisedKVStore) PutWithTTLContext(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	raw, err := s.supervisor.Dispense(ctx)
+	if err != nil {
+		return err
+	}
+	return PutWithTTL(ctx, raw.(ContextKVStore), key, value, ttl)
+}
+
+func (s *SupervisedKVStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	var value []byte
+	err := s.supervisor.Do(ctx, func(raw interface{}) error {
+		var err error
+		value, err = raw.(ContextKVStore).GetContext(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+func (s *SupervisedKVStore) ListContext(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := s.supervisor.Do(ctx, func(raw interface{}) error {
+		var err error
+		keys, err = raw.(ContextKVStore).ListContext(ctx, prefix)
+		return err
+	})
+	return keys, err
+}
+
+func (s *SupervisedKVStore) DeleteContext(ctx context.Context, key string) error {
+	return s.supervisor.Do(ctx, func(raw interface{}) error {
+		return raw.(ContextKVStore).DeleteContext(ctx, key)
+	})
+}
+
+func (s *SupervisedKVStore) HasContext(ctx context.Context, key string) (bool, error) {
+	var exists bool
+	err := s.supervisor.Do(ctx, func(raw interface{}) error {
+		var err error
+		exists, err = raw.(ContextKVStore).HasContext(ctx, key)
+		return err
+	})
+	return exists, err
+}
+
+func (s *SupervisedKVStore) PutMany(items []KeyValue) error {
+	return s.PutManyContext(context.Background(), items)
+}
+
+func (s *SupervisedKVStore) GetMany(keys []string) ([]KeyValue, error) {
+	return s.GetManyContext(context.Background(), keys)
+}
+
+func (s *SupervisedKVStore) PutManyContext(ctx context.Context, items []KeyValue) error {
+	raw, err := s.supervisor.Dispense(ctx)
+	if err != nil {
+		return err
+	}
+	return PutMany(ctx, raw.(ContextKVStore), items)
+}
+
+func (s *SupervisedKVStore) GetManyContext(ctx context.Context, keys []string) ([]KeyValue, error) {
+	var items []KeyValue
+	err := s.supervisor.Do(ctx, func(raw interface{}) error {
+		var err error
+		items, err = GetMany(ctx, raw.(ContextKVStore), keys)
+		return err
+	})
+	return items, err
+}
+
+func (s *SupervisedKVStore) PutStream(key string, r io.Reader) error {
+	return s.PutStreamContext(context.Background(), key, r)
+}
+
+func (s *SupervisedKVStore) GetStream(key string, w io.Writer) error {
+	return s.GetStreamContext(context.Background(), key, w)
+}
+
+func (s *SupervisedKVStore) PutStreamContext(ctx context.Context, key string, r io.Reader) error {
+	raw, err := s.supervisor.Dispense(ctx)
+	if err != nil {
+		return err
+	}
+	return PutStream(ctx, raw.(ContextKVStore), key, r)
+}
+
+// GetStreamContext is not retried, as part of the value may already have been
+// written to w.
+func (s *SupervisedKVStore) GetStreamContext(ctx context.Context, key string, w io.Writer) error {
+	raw, err := s.supervisor.Dispense(ctx)
+	if err != nil {
+		return err
+	}
+	return GetStream(ctx, raw.(ContextKVStore), key, w)
+}
+
+func (s *SupervisedKVStore) History(key string) ([]Revision, error) {
+	return s.HistoryContext(context.Background(), key)
+}
+
+func (s *SupervisedKVStore) GetRevision(key string, revision int64) ([]byte, error) {
+	return s.GetRevisionContext(context.Background(), key, revision)
+}
+
+func (s *SupervisedKVStore) Rollback(key string, revision int64) error {
+	return s.RollbackContext(context.Background(), key, revision)
+}
+
+func (s *SupervisedKVStore) HistoryContext(ctx context.Context, key string) ([]Revision, error) {
+	var revisions []Revision
+	err := s.supervisor.Do(ctx, func(raw interface{}) error {
+		var err error
+		revisions, err = History(ctx, raw.(ContextKVStore), key)
+		return err
+	})
+	return revisions, err
+}
+
+func (s *SupervisedKVStore) GetRevisionContext(ctx context.Context, key string, revision int64) ([]byte, error) {
+	var value []byte
+	err := s.supervisor.Do(ctx, func(raw interface{}) error {
+		var err error
+		value, err = GetRevision(ctx, raw.(ContextKVStore), key, revision)
+		return err
+	})
+	return value, err
+}
+
+func (s *SupervisedKVStore) RollbackContext(ctx context.Context, key string, revision int64) error {
+	raw, err := s.supervisor.Dispense(ctx)
+	if err != nil {
+		return err
+	}
+	return Rollback(ctx, raw.(ContextKVStore), key, revision)
+}
+
+func (s *SupervisedKVStore) Txn(txn Txn) (bool, error) {
+	return s.TxnContext(context.Background(), txn)
+}
+
+func (s *SupervisedKVStore) TxnContext(ctx context.Context, txn Txn) (bool, error) {
+	raw, err := s.supervisor.Dispense(ctx)
+	if err != nil {
+		return false, err
+	}
+	return CommitTxn(ctx, raw.(ContextKVStore), &txn)
+}
+
+// Watch watches the keys of the current plugin. The events stop, with an
+// error, should the plugin crash, so the caller decides whether to watch the
+// restarted plugin.
+func (s *SupervisedKVStore) Watch(ctx context.Context, prefix string) (<-chan WatchEvent, error) {
+	raw, err := s.supervisor.Dispense(ctx)
+	if err != nil {
+		return nil, err
+	}
+	watcher, ok := raw.(Watcher)
+	if !ok {
+		return nil, NewError(Internal, "the plugin does not support watching keys")
+	}
+	return watcher.Watch(ctx, prefix)
+}
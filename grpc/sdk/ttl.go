@@ -0,0 +1,67 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TTLKVStore is implemented by KVStore plugins that can expire keys, e.g. for
+// caching short-lived data.
+//
+// Plugins need not implement it: putting a key with a TTL then fails, rather
+// than storing a key that never expires.
+type TTLKVStore interface {
+	// PutWithTTL puts the value of the key, which expires once the ttl has
+	// passed. An expired key is treated as missing, i.e. Get returns an
+	// ErrNotFound error. Putting the key again without a TTL means it never
+	// expires.
+	PutWithTTL(key string, value []byte, ttl time.Duration) error
+}
+
+// ContextTTLKVStore is the context-aware variant of the TTLKVStore interface.
+//
+// The clients dispensed for the kv_grpc and kv_netrpc plugins implement this
+// interface, with the TTL being sent to the plugin in milliseconds.
+type ContextTTLKVStore interface {
+	PutWithTTLContext(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// errTTLUnsupported is returned when putting a key with a TTL in a store that
+// can not expire keys.
+var errTTLUnsupported = NewError(Internal, "the plugin does not support expiring keys")
+
+// PutWithTTL puts the value of the key, which expires once the ttl has passed.
+// The store must be a ContextTTLKVStore.
+func PutWithTTL(ctx context.Context, kv ContextKVStore, key string, value []byte, ttl time.Duration) error {
+	ttlStore, ok := kv.(ContextTTLKVStore)
+	if !ok {
+		return errTTLUnsupported
+	}
+	return ttlStore.PutWithTTLContext(ctx, key, value, ttl)
+}
+
+// putWithTTL is used by the servers to put a key with a TTL using the
+// plugin's Impl, calling the most capable of the interfaces it implements.
+func putWithTTL(ctx context.Context, impl KVStore, key string, value []byte, ttl time.Duration) error {
+	switch impl := impl.(type) {
+	case ContextTTLKVStore:
+		return impl.PutWithTTLContext(ctx, key, value, ttl)
+	case TTLKVStore:
+		return impl.PutWithTTL(key, value, ttl)
+	}
+	return errTTLUnsupported
+}
+
+// ttlMillis returns the TTL in whole milliseconds, as sent to the plugins,
+// rounding up so that a TTL of less than a millisecond is not sent as zero,
+// which means no TTL. The TTL must be positive.
+func ttlMillis(ttl time.Duration) (int64, error) {
+	if ttl <= 0 {
+		return 0, fmt.Errorf("ttl must be positive, given %s", ttl)
+	}
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond), nil
+}
@@ -0,0 +1,160 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import "context"
+
+// CompareType is the kind of condition checked by a Compare.
+type CompareType int
+
+const (
+	// CompareValue holds when the key exists with the given value.
+	CompareValue CompareType = iota
+
+	// CompareAbsent holds when the key does not exist.
+	CompareAbsent
+)
+
+// Compare is a condition on a key, which must hold for a Txn to be applied.
+type Compare struct {
+	Type  CompareType
+	Key   string
+	Value []byte
+}
+
+// OpType is the kind of change made by an Op.
+type OpType int
+
+const (
+	// OpPut puts the value of the key.
+	OpPut OpType = iota
+
+	// OpDelete deletes the key.
+	OpDelete
+)
+
+// Op is a change made to a key by a Txn.
+type Op struct {
+	Type  OpType
+	Key   string
+	Value []byte
+}
+
+// Txn is a transaction on several keys: when every compare holds, all the ops
+// are applied, in order, otherwise none of them are. Build a Txn using NewTxn,
+// and commit it using CommitTxn:
+//
+//	txn := sdk.NewTxn().
+//		IfAbsent("lock").
+//		Put("lock", []byte("worker-1")).
+//		Delete("queue/1")
+//	committed, err := sdk.CommitTxn(ctx, kv, txn)
+type Txn struct {
+	Compares []Compare
+	Ops      []Op
+}
+
+// NewTxn returns an empty transaction.
+func NewTxn() *Txn {
+	return &Txn{}
+}
+
+// IfValue adds the condition that the key exists with the value, as returned
+// by Get.
+func (t *Txn) IfValue(key string, value []byte) *Txn {
+	t.Compares = append(t.Compares, Compare{Type: CompareValue, Key: key, Value: value})
+	return t
+}
+
+// IfAbsent adds the condition that the key does not exist.
+func (t *Txn) IfAbsent(key string) *Txn {
+	t.Compares = append(t.Compares, Compare{Type: CompareAbsent, Key: key})
+	return t
+}
+
+// Put adds the put of the value of the key.
+func (t *Txn) Put(key string, value []byte) *Txn {
+	t.Ops = append(t.Ops, Op{Type: OpPut, Key: key, Value: value})
+	return t
+}
+
+// Delete adds the delete of the key.
+func (t *Txn) Delete(key string) *Txn {
+	t.Ops = append(t.Ops, Op{Type: OpDelete, Key: key})
+	return t
+}
+
+// Keys returns the keys of the compares and ops, e.g. for checking them with
+// ValidateKey before the transaction is applied.
+func (t *Txn) Keys() []string {
+	keys := make([]string, 0, len(t.Compares)+len(t.Ops))
+	for _, c := range t.Compares {
+		keys = append(keys, c.Key)
+	}
+	for _, op := range t.Ops {
+		keys = append(keys, op.Key)
+	}
+	return keys
+}
+
+// TxnKVStore is implemented by KVStore plugins that can apply a Txn
+// atomically, i.e. either every op is applied, or none are.
+//
+// Plugins need not implement it: committing a transaction then fails, as the
+// sdk can not apply the ops atomically using the KVStore methods.
+type TxnKVStore interface {
+	// Txn applies the ops of the transaction when all its compares hold,
+	// reporting whether they were applied. A compare that does not hold is
+	// not an error. When an error is returned, none of the ops have been
+	// applied, except for an ErrPartiallyApplied error, returned when the
+	// ops applied before the failure could not be undone.
+	Txn(txn Txn) (bool, error)
+}
+
+// ContextTxnKVStore is the context-aware variant of the TxnKVStore interface.
+//
+// The clients dispensed for the kv_grpc and kv_netrpc plugins implement this
+// interface.
+type ContextTxnKVStore interface {
+	TxnContext(ctx context.Context, txn Txn) (bool, error)
+}
+
+// errTxnUnsupported is returned when committing a transaction to a store that
+// can not apply them.
+var errTxnUnsupported = NewError(Internal, "the plugin does not support transactions")
+
+// CommitTxn applies the ops of the transaction when all its compares hold,
+// reporting whether they were applied. The store must be a ContextTxnKVStore.
+func CommitTxn(ctx context.Context, kv ContextKVStore, txn *Txn) (bool, error) {
+	txnStore, ok := kv.(ContextTxnKVStore)
+	if !ok {
+		return false, errTxnUnsupported
+	}
+	return txnStore.TxnContext(ctx, *txn)
+}
+
+// applyTxn is used by the servers to apply a transaction using the plugin's
+// Impl, calling the most capable of the interfaces it implements. The compare
+// and op types are checked first, so the plugins need only handle the known
+// types.
+func applyTxn(ctx context.Context, impl KVStore, txn Txn) (bool, error) {
+	for _, c := range txn.Compares {
+		if c.Type != CompareValue && c.Type != CompareAbsent {
+			return false, NewError(Internal, "key %q: unknown compare type %d", c.Key, c.Type)
+		}
+	}
+	for _, op := range txn.Ops {
+		if op.Type != OpPut && op.Type != OpDelete {
+			return false, NewError(Internal, "key %q: unknown op type %d", op.Key, op.Type)
+		}
+	}
+
+	switch impl := impl.(type) {
+	case ContextTxnKVStore:
+		return impl.TxnContext(ctx, txn)
+	case TxnKVStore:
+		return impl.Txn(txn)
+	}
+	return false, errTxnUnsupported
+}
@@ -0,0 +1,51 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+)
+
+// EventType is the kind of change made to a key.
+type EventType int
+
+const (
+	EventPut    EventType = iota // the key was written
+	EventDelete                  // the key was removed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// WatchEvent is sent by Watch for each change made to a watched key.
+//
+// When watching fails, such as when the plugin exits, a final event is sent
+// with the Err set, before the channel is closed.
+type WatchEvent struct {
+	Type EventType
+	Key  string
+	Err  error
+}
+
+// Watcher is implemented by KVStore plugins that can report the changes made
+// to their keys.
+//
+// Watch returns a channel receiving an event for each key starting with the
+// prefix that is put or deleted, with an empty prefix watching every key. The
+// channel is closed once the context is done.
+//
+// Watching requires a stream from the plugin to the host, so is only supported
+// over gRPC. The client dispensed for the kv_grpc plugin always implements
+// Watcher, with the plugin returning an error when it has no support.
+type Watcher interface {
+	Watch(ctx context.Context, prefix string) (<-chan WatchEvent, error)
+}
@@ -0,0 +1,240 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// The serve command listens on a Unix socket by default. A TCP address,
+// e.g. "127.0.0.1:8080", can be given instead.
+const (
+	defaultListenAddr = "unix:kv.sock"
+	unixAddrPrefix    = "unix:"
+)
+
+// maxValueSize limits the size of the request body of a put.
+const maxValueSize = 1 << 20
+
+// serve keeps the plugin running, and exposes the KV store over an HTTP JSON
+// API, so that many operations can be made against a single plugin process.
+// It returns once the application is interrupted.
+func serve(kv *sdk.SupervisedKVStore, args cliArgs) error {
+	listener, err := listen(args.listenAddr)
+	if err != nil {
+		return err
+	}
+
+	s := &kvServer{kv: kv, pluginName: args.pluginName, timeout: args.timeout}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys", s.handleList)
+	mux.HandleFunc("/keys/", s.handleKey)
+	mux.HandleFunc("/status", s.handleStatus)
+	server := &http.Server{Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.Serve(listener)
+	}()
+	fmt.Println("Serving the KV store on", args.listenAddr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Wait for the requests in progress to complete before the plugin is killed.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), args.timeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// listen returns a listener for either a Unix socket, or a TCP address.
+// A socket file left behind by a previous server is removed, but not one
+// that is still in use.
+func listen(addr string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(addr, unixAddrPrefix)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("socket %s is already in use", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// kvServer handles the HTTP requests, with each request to the plugin made
+// with the same timeout as the other commands.
+//
+//	GET    /keys?prefix=<prefix>  list the keys
+//	GET    /keys/<key>            get a value
+//	PUT    /keys/<key>            put a value: {"value": "..."}
+//	DELETE /keys/<key>            delete a key
+//	HEAD   /keys/<key>            check whether the key exists
+//	GET    /status                the plugin name and restart count
+type kvServer struct {
+	kv         *sdk.SupervisedKVStore
+	pluginName string
+	timeout    time.Duration
+}
+
+// keyValue is the JSON of a key and its value, which is base64 encoded so
+// that values need not be text.
+type keyValue struct {
+	Key   string `json:"key,omitempty"`
+	Value []byte `json:"value"`
+}
+
+type keyList struct {
+	Keys []string `json:"keys"`
+}
+
+type serverStatus struct {
+	Plugin   string `json:"plugin"`
+	Restarts int    `json:"restarts"`
+}
+
+type errorResponse struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+func (s *kvServer) handleList(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	keys, err := s.kv.ListContext(ctx, r.URL.Query().Get("prefix"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if keys == nil {
+		keys = []string{}
+	}
+	writeJSON(w, http.StatusOK, keyList{Keys: keys})
+}
+
+func (s *kvServer) handleKey(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimPrefix(r.URL.Path, "/keys/")
+	if key == "" {
+		writeError(w, sdk.NewError(sdk.InvalidKey, "key must not be empty"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.timeout)
+	defer cancel()
+
+	switch r.Method {
+	case http.MethodGet:
+		value, err := s.kv.GetContext(ctx, key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, keyValue{Key: key, Value: value})
+	case http.MethodPut:
+		var kv keyValue
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxValueSize)).Decode(&kv); err != nil {
+			writeJSON(w, http.StatusBadRequest, errorResponse{Code: "invalid_request", Error: err.Error()})
+			return
+		}
+		if err := s.kv.PutContext(ctx, key, kv.Value); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		if err := s.kv.DeleteContext(ctx, key); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodHead:
+		exists, err := s.kv.HasContext(ctx, key)
+		if err != nil {
+			w.WriteHeader(httpStatus(err))
+		} else if !exists {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete, http.MethodHead)
+	}
+}
+
+func (s *kvServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	writeJSON(w, http.StatusOK, serverStatus{Plugin: s.pluginName, Restarts: s.kv.Restarts()})
+}
+
+// writeError writes the error returned by the plugin, with the HTTP status
+// matching the kind of error, in the same way as the exit codes.
+func writeError(w http.ResponseWriter, err error) {
+	code := string(sdk.Internal)
+	var e *sdk.Error
+	if errors.As(err, &e) {
+		code = string(e.Code)
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		code = "deadline_exceeded"
+	}
+	writeJSON(w, httpStatus(err), errorResponse{Code: code, Error: err.Error()})
+}
+
+func httpStatus(err error) int {
+	switch {
+	case errors.Is(err, sdk.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, sdk.ErrInvalidKey):
+		return http.StatusBadRequest
+	case errors.Is(err, sdk.ErrPermissionDenied):
+		return http.StatusForbidden
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Code: "method_not_allowed", Error: "method not allowed"})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,68 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// parseTxn returns the transaction given by the steps of the txn command, each
+// being one of:
+//
+//	if-value:<key>=<value>
+//	if-absent:<key>
+//	put:<key>=<value>
+//	delete:<key>
+//
+// The key ends at the first "=", so it can not contain one when followed by a
+// value.
+func parseTxn(steps []string) (*sdk.Txn, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("a transaction must have at least one step, e.g. put:<key>=<value>")
+	}
+
+	txn := sdk.NewTxn()
+	for _, step := range steps {
+		kind, arg, _ := strings.Cut(step, ":")
+		key, value, hasValue := strings.Cut(arg, "=")
+
+		switch kind {
+		case "if-value":
+			if !hasValue {
+				return nil, fmt.Errorf("invalid step %q, must be if-value:<key>=<value>", step)
+			}
+			txn.IfValue(key, []byte(value))
+		case "if-absent":
+			txn.IfAbsent(arg)
+		case "put":
+			if !hasValue {
+				return nil, fmt.Errorf("invalid step %q, must be put:<key>=<value>", step)
+			}
+			txn.Put(key, []byte(value))
+		case "delete":
+			txn.Delete(arg)
+		default:
+			return nil, fmt.Errorf("invalid step %q, must start with 'if-value:', 'if-absent:', 'put:', or 'delete:'", step)
+		}
+	}
+	return txn, nil
+}
+
+// commitTxn commits the transaction, printing whether its ops were applied.
+func commitTxn(ctx context.Context, kv sdk.ContextKVStore, txn *sdk.Txn) error {
+	committed, err := sdk.CommitTxn(ctx, kv, txn)
+	if err != nil {
+		return err
+	}
+	if committed {
+		fmt.Println("committed")
+	} else {
+		fmt.Println("not committed, a condition did not hold")
+	}
+	return nil
+}
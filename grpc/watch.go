@@ -0,0 +1,33 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mrcook/go-plugin-examples/grpc/sdk"
+)
+
+// watch prints the changes made to the keys starting with the prefix given as
+// the key argument, until interrupted.
+func watch(kv *sdk.SupervisedKVStore, args cliArgs) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	events, err := kv.Watch(ctx, args.key)
+	if err != nil {
+		return err
+	}
+	for event := range events {
+		if event.Err != nil {
+			return event.Err
+		}
+		fmt.Println(event.Type, event.Key)
+	}
+	return nil
+}
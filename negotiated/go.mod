@@ -5,11 +5,15 @@ go 1.20
 require (
 	github.com/hashicorp/go-hclog v1.5.0
 	github.com/hashicorp/go-plugin v1.4.9
+	github.com/mrcook/go-plugin-examples/discovery v0.0.0
+	github.com/mrcook/go-plugin-examples/repl v0.0.0
+	github.com/mrcook/go-plugin-examples/storage v0.0.0
 	google.golang.org/grpc v1.54.0
 	google.golang.org/protobuf v1.30.0
 )
 
 require (
+	github.com/chzyer/readline v1.5.1 // indirect
 	github.com/fatih/color v1.15.0 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/hashicorp/yamux v0.1.1 // indirect
@@ -23,3 +27,9 @@ require (
 	golang.org/x/text v0.9.0 // indirect
 	google.golang.org/genproto v0.0.0-20230410155749-daa745c078e1 // indirect
 )
+
+replace (
+	github.com/mrcook/go-plugin-examples/discovery => ../discovery
+	github.com/mrcook/go-plugin-examples/repl => ../repl
+	github.com/mrcook/go-plugin-examples/storage => ../storage
+)
@@ -7,32 +7,119 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
-	"os/exec"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-plugin"
 
+	"github.com/mrcook/go-plugin-examples/discovery"
 	"github.com/mrcook/go-plugin-examples/negotitated/sdk"
 )
 
+// Plugin executables are named "kv-<name>", e.g. "kv-plugin", and are found
+// in the plugin directories by discovery.
+const pluginPattern = "kv-*"
+
 func main() {
 	// Fetch the plugin version, command, key, and value from the CLI args.
 	args := parseFlags()
 
+	// Start the plugin using the requested version.
+	pluginClient, kv, err := startPlugin(args.pluginVersion, args.pluginName, args.pluginDirs)
+	if err != nil {
+		fmt.Println("Error:", err.Error())
+		os.Exit(1)
+	}
+	defer pluginClient.Kill()
+
+	// The repl command reads commands until the user exits, and may switch
+	// to another plugin version, so it stops the plugin itself.
+	if args.command == "repl" {
+		if err := runREPL(pluginClient, kv, args); err != nil {
+			fmt.Println("Error:", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The deadline is sent along with the request to the plugin.
+	ctx, cancel := context.WithTimeout(context.Background(), args.timeout)
+	defer cancel()
+
+	if args.command == "get" {
+		result, err := kv.GetContext(ctx, args.key)
+		if err != nil {
+			exitWithError(err)
+		}
+
+		// Let's see what the plugin returns!
+		fmt.Println(string(result))
+	} else if args.command == "put" {
+		var err error
+		if args.ttl > 0 {
+			err = sdk.PutWithTTL(ctx, kv, args.key, []byte(args.value), args.ttl)
+		} else {
+			err = kv.PutContext(ctx, args.key, []byte(args.value))
+		}
+		if err != nil {
+			exitWithError(err)
+		}
+	} else if args.command == "delete" {
+		err := kv.DeleteContext(ctx, args.key)
+		if err != nil {
+			exitWithError(err)
+		}
+	} else if args.command == "has" {
+		exists, err := kv.HasContext(ctx, args.key)
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(exists)
+	}
+}
+
+// startPlugin finds the named plugin in the plugin directories, and starts it
+// using the given plugin version.
+func startPlugin(pluginVersion int, pluginName, pluginDirs string) (*plugin.Client, sdk.ContextKVStore, error) {
+	// Find the requested plugin, making sure it supports the plugin version.
+	// Only plugins with a manifest are used, and the executable must match
+	// its checksum.
+	registry, err := discovery.Discover(discovery.Config{
+		Dirs:             discovery.SplitDirs(pluginDirs),
+		Pattern:          pluginPattern,
+		ProtocolVersions: []int{2, 3},
+		RequireManifest:  true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	kvPlugin, err := registry.Lookup(pluginName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := kvPlugin.Verify(); err != nil {
+		return nil, nil, err
+	}
+	if !kvPlugin.Supports(pluginVersion) {
+		return nil, nil, fmt.Errorf("plugin %q does not support version %d", kvPlugin.Name, pluginVersion)
+	}
+
 	// Initialize the array of versioned plugins that can be dispensed.
 	plugins := map[int]plugin.PluginSet{}
 
 	// Both versions can be supported, but switch the implementation to
 	// demonstrate version negotiation.
-	if args.pluginVersion == 2 {
+	if pluginVersion == 2 {
 		plugins[2] = plugin.PluginSet{
 			sdk.KVStorePluginName: &sdk.KVPluginRPC{},
 		}
-	} else if args.pluginVersion == 3 {
+	} else if pluginVersion == 3 {
 		plugins[3] = plugin.PluginSet{
 			sdk.KVStorePluginName: &sdk.KVPluginGRPC{},
 		}
@@ -42,65 +129,84 @@ func main() {
 	// - HandshakeConfig: is required
 	// - VersionedPlugins: is an array of plugin versions, and their plugin.Plugin implementations
 	// - Cmd: points to the compiled binary of your plugin
+	// - SecureConfig: verifies the binary checksum again, just before it is launched
 	// - AllowedProtocols: by default only net/rpc is allowed, so add gRPC support
 	// - Logger: configured to discard all logs
 	pluginClient := plugin.NewClient(&plugin.ClientConfig{
 		HandshakeConfig:  sdk.HandshakeConfig,
 		VersionedPlugins: plugins,
-		Cmd:              exec.Command("./kv-plugin"),
+		Cmd:              kvPlugin.Cmd(),
+		SecureConfig:     kvPlugin.SecureConfig(),
 		AllowedProtocols: []plugin.Protocol{plugin.ProtocolNetRPC, plugin.ProtocolGRPC},
 		Logger:           logger(),
 	})
-	defer pluginClient.Kill()
 
 	// Get the client for RPC communication.
 	client, err := pluginClient.Client()
 	if err != nil {
-		fmt.Println("Error:", err.Error())
-		os.Exit(1)
+		pluginClient.Kill()
+		return nil, nil, err
 	}
 
 	// Request the plugin.
 	raw, err := client.Dispense(sdk.KVStorePluginName)
 	if err != nil {
-		fmt.Println("Error:", err.Error())
-		os.Exit(1)
+		pluginClient.Kill()
+		return nil, nil, err
 	}
 
 	// As Dispense() returns an interface, we need to cast it to the plugin
 	// type supported by the host application, which in our case is a KVStore store.
 	// This feels like a normal interface implementation, but is in fact
 	// communicating over an RPC connection.
-	kv := raw.(sdk.KVStore)
+	// The context-aware variant is used so a hung plugin can't block forever.
+	return pluginClient, raw.(sdk.ContextKVStore), nil
+}
 
-	if args.command == "get" {
-		result, err := kv.Get(args.key)
-		if err != nil {
-			fmt.Println("Error:", err.Error())
-			os.Exit(1)
-		}
+// Exit codes returned by the application, so that scripts can tell a missing
+// key apart from other failures.
+const (
+	exitError            = 1
+	exitNotFound         = 2
+	exitInvalidKey       = 3
+	exitPermissionDenied = 4
+)
 
-		// Let's see what the plugin returns!
-		fmt.Println(string(result))
-	} else if args.command == "put" {
-		err := kv.Put(args.key, []byte(args.value))
-		if err != nil {
-			fmt.Println("Error:", err.Error())
-			os.Exit(1)
-		}
+// exitWithError prints the error returned by the plugin, and exits with the
+// code matching the kind of error.
+func exitWithError(err error) {
+	fmt.Println("Error:", err.Error())
+
+	switch {
+	case errors.Is(err, sdk.ErrNotFound):
+		os.Exit(exitNotFound)
+	case errors.Is(err, sdk.ErrInvalidKey):
+		os.Exit(exitInvalidKey)
+	case errors.Is(err, sdk.ErrPermissionDenied):
+		os.Exit(exitPermissionDenied)
+	default:
+		os.Exit(exitError)
 	}
 }
 
 // Contains all the data required to run the application.
 type cliArgs struct {
-	pluginVersion int    // the plugin version to use
-	command       string // get or put command
-	key           string // custom key name (appended to the KV store filename)
-	value         string // comment to be saved in the file
+	pluginVersion int           // the plugin version to use
+	pluginName    string        // the discovered plugin to use
+	pluginDirs    string        // directories to search for plugins
+	timeout       time.Duration // how long to wait for the plugin to respond
+	ttl           time.Duration // how long a put key lives before it expires, zero for never
+	command       string        // get, put, delete, has, or repl command
+	key           string        // custom key name (appended to the KV store filename)
+	value         string        // comment to be saved in the file
 }
 
 func parseFlags() cliArgs {
 	pluginVersion := flag.Int("plugin", 3, "Plugin version to use: 2 (net/rpc) or 3 (gRPC)")
+	pluginName := flag.String("plugin-name", "plugin", "Name of the discovered plugin to use.")
+	pluginDirs := flag.String("plugin-dir", ".", "Directories to search for plugins, separated by the OS path list separator.")
+	timeout := flag.Duration("timeout", 5*time.Second, "How long to wait for the plugin to respond.")
+	ttl := flag.Duration("ttl", 0, "How long a put key lives before it expires, e.g. 30s. Keys never expire by default.")
 	flag.Parse()
 
 	if *pluginVersion < 2 || *pluginVersion > 3 {
@@ -109,14 +215,24 @@ func parseFlags() cliArgs {
 	}
 
 	command := flag.Arg(0)
-	if command != "get" && command != "put" {
-		fmt.Printf("invalid command, must be 'get' or 'put', given '%s'\n", command)
+	switch command {
+	case "get", "put", "delete", "has", "repl":
+	default:
+		fmt.Printf("invalid command, must be 'get', 'put', 'delete', 'has', or 'repl', given '%s'\n", command)
+		os.Exit(1)
+	}
+
+	if *ttl != 0 && command != "put" {
+		fmt.Println("the --ttl flag can only be used with the 'put' command")
+		os.Exit(1)
+	} else if *ttl < 0 {
+		fmt.Println("the --ttl flag must not be negative")
 		os.Exit(1)
 	}
 
 	key := flag.Arg(1)
 	value := flag.Arg(2)
-	if len(key) == 0 {
+	if len(key) == 0 && command != "repl" {
 		fmt.Println("key must be present")
 		os.Exit(1)
 	} else if command == "put" && len(value) == 0 {
@@ -126,6 +242,10 @@ func parseFlags() cliArgs {
 
 	return cliArgs{
 		pluginVersion: *pluginVersion,
+		pluginName:    *pluginName,
+		pluginDirs:    *pluginDirs,
+		timeout:       *timeout,
+		ttl:           *ttl,
 		command:       command,
 		key:           key,
 		value:         value,
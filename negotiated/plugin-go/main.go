@@ -7,73 +7,130 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/hashicorp/go-plugin"
 
 	"github.com/mrcook/go-plugin-examples/negotitated/sdk"
+	"github.com/mrcook/go-plugin-examples/storage"
 )
 
-// The KV store filename prefix for this plugin.
+// The KV store filename prefix for this plugin, whose files are kept in the
+// data directory.
 const filenamePrefix = "kv_store_"
 
+// How often the files of expired keys are removed.
+const sweepInterval = time.Minute
+
 // GrpcPlugin is v3 of our custom plugin: it's a real implementation of the
-// KVStore plugin type that writes to a local file with the key name and the
-// contents are the value of the key.
+// KVStore plugin type that writes to a file in the data directory with the key
+// name and the contents are the value of the key.
 // It communicates with the host application via gRPC.
-type GrpcPlugin struct{}
+type GrpcPlugin struct {
+	store *storage.FileStore
+}
 
 // Put will overwrite the file contents with the new key/value data.
 // When the file is written the plugin version number will be appended.
-func (GrpcPlugin) Put(key string, value []byte) error {
+func (p GrpcPlugin) Put(key string, value []byte) error {
+	value = []byte(fmt.Sprintf("%s\n\nWritten from plugin version 3\n", string(value)))
+	return p.store.WriteFile(key, value)
+}
+
+// PutWithTTL writes the file as for Put, with the key expiring once the ttl has
+// passed.
+func (p GrpcPlugin) PutWithTTL(key string, value []byte, ttl time.Duration) error {
 	value = []byte(fmt.Sprintf("%s\n\nWritten from plugin version 3\n", string(value)))
-	return os.WriteFile(filenamePrefix+key, value, 0644)
+	return p.store.WriteFileWithExpiry(key, value, time.Now().Add(ttl))
 }
 
 // Get reads the file and returns the value stored for the matching key.
 // Before returning the file contents, the plugin version number is appended.
-func (GrpcPlugin) Get(key string) ([]byte, error) {
-	d, err := os.ReadFile(filenamePrefix + key)
+func (p GrpcPlugin) Get(key string) ([]byte, error) {
+	d, err := p.store.ReadFile(key)
 	if err != nil {
 		return nil, err
 	}
 	return append(d, []byte("Read by plugin version 3\n")...), nil
 }
 
+// Delete removes the file for the matching key, if it exists.
+func (p GrpcPlugin) Delete(key string) error {
+	return p.store.Remove(key)
+}
+
+// Has reports whether a file exists for the matching key.
+func (p GrpcPlugin) Has(key string) (bool, error) {
+	return p.store.Exists(key)
+}
+
 // NetRpcPlugin is v2 of our custom plugin: it's a real implementation of the
-// KVStore plugin type that writes to a local file with the key name and the
-// contents are the value of the key.
+// KVStore plugin type that writes to a file in the data directory with the key
+// name and the contents are the value of the key.
 // It communicates with the host application via net/rpc.
-type NetRpcPlugin struct{}
+type NetRpcPlugin struct {
+	store *storage.FileStore
+}
 
 // Put will overwrite the file contents with the new key/value data.
 // When the file is written the plugin version number will be appended.
-func (NetRpcPlugin) Put(key string, value []byte) error {
+func (p NetRpcPlugin) Put(key string, value []byte) error {
 	value = []byte(fmt.Sprintf("%s\n\nWritten from plugin version 2\n", string(value)))
-	return os.WriteFile(filenamePrefix+key, value, 0644)
+	return p.store.WriteFile(key, value)
+}
+
+// PutWithTTL writes the file as for Put, with the key expiring once the ttl has
+// passed.
+func (p NetRpcPlugin) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	value = []byte(fmt.Sprintf("%s\n\nWritten from plugin version 2\n", string(value)))
+	return p.store.WriteFileWithExpiry(key, value, time.Now().Add(ttl))
 }
 
 // Get reads the file and returns the value stored for the matching key.
 // Before returning the file contents, the plugin version number is appended.
-func (NetRpcPlugin) Get(key string) ([]byte, error) {
-	d, err := os.ReadFile(filenamePrefix + key)
+func (p NetRpcPlugin) Get(key string) ([]byte, error) {
+	d, err := p.store.ReadFile(key)
 	if err != nil {
 		return nil, err
 	}
 	return append(d, []byte("Read by plugin version 2\n")...), nil
 }
 
+// Delete removes the file for the matching key, if it exists.
+func (p NetRpcPlugin) Delete(key string) error {
+	return p.store.Remove(key)
+}
+
+// Has reports whether a file exists for the matching key.
+func (p NetRpcPlugin) Has(key string) (bool, error) {
+	return p.store.Exists(key)
+}
+
 // go-plugin's are normal Go applications so require a main entry point.
 // Once the host application has loaded (dispensed) the plugin, go-plugin will
 // start the plugin, and manage its full lifecycle.
 func main() {
+	// Both versions of the plugin use the same files, kept in the data
+	// directory given by the host application.
+	store, err := storage.NewFileStore(storage.DataDir(), filenamePrefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// Expired keys are hidden by the store, with their files being removed
+	// in the background.
+	go store.SweepEvery(context.Background(), sweepInterval)
+
 	// Assign the version to the required plugin type.
 	// - version 2 uses NetRPC
 	// - version 3 uses GRPC
 	versionedPlugins := map[int]plugin.PluginSet{
-		2: {sdk.KVStorePluginName: &sdk.KVPluginRPC{Impl: &NetRpcPlugin{}}},
-		3: {sdk.KVStorePluginName: &sdk.KVPluginGRPC{Impl: &GrpcPlugin{}}},
+		2: {sdk.KVStorePluginName: &sdk.KVPluginRPC{Impl: &NetRpcPlugin{store: store}}},
+		3: {sdk.KVStorePluginName: &sdk.KVPluginGRPC{Impl: &GrpcPlugin{store: store}}},
 	}
 
 	// start listening for incoming gRPC requests.
@@ -0,0 +1,128 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/go-plugin"
+
+	"github.com/mrcook/go-plugin-examples/negotitated/sdk"
+	"github.com/mrcook/go-plugin-examples/repl"
+)
+
+// The plugin versions the switch-plugin command accepts, which can also be
+// given by the protocol they use.
+var pluginVersions = map[string]int{
+	"2":    2,
+	"3":    3,
+	"rpc":  2,
+	"grpc": 3,
+}
+
+// replSession holds the plugin used by the REPL, which is replaced by the
+// switch-plugin command.
+type replSession struct {
+	args          cliArgs
+	pluginVersion int
+	pluginClient  *plugin.Client
+	kv            sdk.ContextKVStore
+	repl          *repl.REPL
+}
+
+// runREPL reads commands until the user exits, making each request to the
+// same plugin process. The plugin in use when the REPL exits is stopped.
+func runREPL(pluginClient *plugin.Client, kv sdk.ContextKVStore, args cliArgs) error {
+	s := &replSession{
+		args:          args,
+		pluginVersion: args.pluginVersion,
+		pluginClient:  pluginClient,
+		kv:            kv,
+	}
+	defer func() { s.pluginClient.Kill() }()
+
+	s.repl = repl.New(repl.Config{
+		Prompt:      "kv> ",
+		HistoryFile: repl.HistoryFile("kv_negotiated"),
+		Commands: []repl.Command{
+			{Name: "get", Args: "<key>", Help: "print the value of the key", MinArgs: 1, Run: s.get},
+			{Name: "put", Args: "<key> <value>", Help: "save the value for the key", MinArgs: 2, Run: s.put},
+			{Name: "delete", Args: "<key>", Help: "delete the key", MinArgs: 1, Run: s.delete},
+			{Name: "has", Args: "<key>", Help: "print whether the key exists", MinArgs: 1, Run: s.has},
+			{Name: "switch-plugin", Args: "<version>", Help: "switch to another plugin version: 2 (rpc) or 3 (grpc)", MinArgs: 1, Completions: []string{"2", "3", "grpc", "rpc"}, Run: s.switchPlugin},
+			{Name: "stats", Help: "print the plugin version in use, and the time taken by each command", Run: s.stats},
+		},
+	})
+	return s.repl.Run()
+}
+
+func (s *replSession) get(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.args.timeout)
+	defer cancel()
+
+	value, err := s.kv.GetContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(value))
+	return nil
+}
+
+func (s *replSession) put(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.args.timeout)
+	defer cancel()
+
+	// The value is everything following the key.
+	value := strings.Join(args[1:], " ")
+	return s.kv.PutContext(ctx, args[0], []byte(value))
+}
+
+func (s *replSession) delete(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.args.timeout)
+	defer cancel()
+
+	return s.kv.DeleteContext(ctx, args[0])
+}
+
+func (s *replSession) has(args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.args.timeout)
+	defer cancel()
+
+	exists, err := s.kv.HasContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(exists)
+	return nil
+}
+
+// switchPlugin starts the plugin using another version, and only once it is
+// running, stops the plugin that was in use.
+func (s *replSession) switchPlugin(args []string) error {
+	pluginVersion, ok := pluginVersions[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown plugin version %q, must be 2 (rpc) or 3 (grpc)", args[0])
+	}
+
+	pluginClient, kv, err := startPlugin(pluginVersion, s.args.pluginName, s.args.pluginDirs)
+	if err != nil {
+		return err
+	}
+	s.pluginClient.Kill()
+
+	s.pluginVersion = pluginVersion
+	s.pluginClient = pluginClient
+	s.kv = kv
+	fmt.Printf("Switched to plugin version %d\n", pluginVersion)
+	return nil
+}
+
+func (s *replSession) stats(_ []string) error {
+	fmt.Printf("Plugin %s, version %d, using %s\n\n", s.args.pluginName, s.pluginVersion, s.pluginClient.Protocol())
+	s.repl.WriteStats(os.Stdout)
+	return nil
+}
@@ -0,0 +1,200 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mrcook/go-plugin-examples/storage"
+)
+
+// ErrorCode identifies the kind of error returned by a KVStore plugin.
+type ErrorCode string
+
+// The error codes that are preserved across the plugin boundary.
+const (
+	NotFound         ErrorCode = "not_found"
+	InvalidKey       ErrorCode = "invalid_key"
+	PermissionDenied ErrorCode = "permission_denied"
+	Internal         ErrorCode = "internal"
+)
+
+// Error is the error type returned by KVStore plugins. When a plugin returns
+// an Error, the host application receives an Error with the same code,
+// whether the plugin communicates over gRPC or net/rpc.
+//
+// Use errors.Is with one of the Err* values to check the kind of error:
+//
+//	if errors.Is(err, sdk.ErrNotFound) { ... }
+//
+// Errors that are not returned by the plugin, such as a connection that was
+// shut down, are Internal errors wrapping the original error, so it can still
+// be checked, e.g. with errors.Is(err, rpc.ErrShutdown).
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Err     error // the error wrapped, if any
+}
+
+// NewError returns an Error with the given code, and a message formatted
+// according to the format specifier.
+func NewError(code ErrorCode, format string, a ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error wrapped, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the target is an Error with the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// These errors are used with errors.Is, to check the kind of error returned.
+var (
+	ErrNotFound         = &Error{Code: NotFound, Message: "key not found"}
+	ErrInvalidKey       = &Error{Code: InvalidKey, Message: "invalid key"}
+	ErrPermissionDenied = &Error{Code: PermissionDenied, Message: "permission denied"}
+	ErrInternal         = &Error{Code: Internal, Message: "internal error"}
+)
+
+// ValidateKey returns an InvalidKey error when the key is empty, or contains
+// characters that are not safe to use in a filename.
+func ValidateKey(key string) error {
+	if len(key) == 0 {
+		return NewError(InvalidKey, "key must not be empty")
+	}
+	if strings.ContainsAny(key, "/\\\x00") {
+		return NewError(InvalidKey, "key %q must not contain path separators", key)
+	}
+	return nil
+}
+
+// FileError converts an error from the os package, such as from os.ReadFile,
+// into an Error for the given key.
+func FileError(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return NewError(NotFound, "key %q not found", key)
+	case errors.Is(err, os.ErrPermission):
+		return NewError(PermissionDenied, "permission denied for key %q", key)
+	default:
+		return NewError(Internal, "key %q: %s", key, err)
+	}
+}
+
+// toError returns the err as an Error. The errors of a storage.FileStore keep
+// their kind, while any other untyped errors are treated as Internal errors.
+func toError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	code := Internal
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		code = NotFound
+	case errors.Is(err, storage.ErrInvalidKey):
+		code = InvalidKey
+	case errors.Is(err, storage.ErrPermissionDenied):
+		code = PermissionDenied
+	}
+	return &Error{Code: code, Message: err.Error()}
+}
+
+// grpcCodes maps each ErrorCode to a gRPC status code.
+var grpcCodes = map[ErrorCode]codes.Code{
+	NotFound:         codes.NotFound,
+	InvalidKey:       codes.InvalidArgument,
+	PermissionDenied: codes.PermissionDenied,
+	Internal:         codes.Internal,
+}
+
+// toStatus converts an error returned by a plugin into a gRPC status error.
+// Context errors keep their own status codes, so the host can tell that the
+// request timed out or was cancelled.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return status.FromContextError(err).Err()
+	}
+	e := toError(err)
+	return status.Error(grpcCodes[e.Code], e.Message)
+}
+
+// fromStatus converts a gRPC status error back into an Error. Plugins not
+// written in Go, such as the Python plugin, can return these status codes to
+// have their errors typed.
+func fromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	s := status.Convert(err)
+	switch s.Code() {
+	case codes.DeadlineExceeded:
+		return context.DeadlineExceeded
+	case codes.Canceled:
+		return context.Canceled
+	}
+	for code, grpcCode := range grpcCodes {
+		if s.Code() == grpcCode {
+			return &Error{Code: code, Message: s.Message()}
+		}
+	}
+	return &Error{Code: Internal, Message: s.Message(), Err: err}
+}
+
+// net/rpc only sends the error message to the client, so the code is encoded
+// into the message using this prefix, e.g. "kv_error:not_found:message".
+const rpcErrorPrefix = "kv_error:"
+
+// toRPCError converts an error returned by a plugin into a net/rpc error.
+func toRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+	e := toError(err)
+	return errors.New(rpcErrorPrefix + string(e.Code) + ":" + e.Message)
+}
+
+// fromRPCError converts a net/rpc error back into an Error.
+func fromRPCError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	// Errors not returned by the plugin, such as a shutdown connection,
+	// are not a rpc.ServerError, and are wrapped so they can be checked.
+	var serverErr rpc.ServerError
+	if !errors.As(err, &serverErr) {
+		return &Error{Code: Internal, Message: err.Error(), Err: err}
+	}
+
+	msg := strings.TrimPrefix(string(serverErr), rpcErrorPrefix)
+	if code, message, ok := strings.Cut(msg, ":"); ok && len(msg) < len(serverErr) {
+		return &Error{Code: ErrorCode(code), Message: message}
+	}
+	return &Error{Code: Internal, Message: string(serverErr)}
+}
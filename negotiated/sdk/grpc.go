@@ -6,43 +6,159 @@ package sdk
 
 import (
 	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/mrcook/go-plugin-examples/negotitated/proto"
 )
 
 // GRPCClient is an implementation of KVStore that talks over RPC.
+// It also implements ContextKVStore, with gRPC sending any deadline set on
+// the context along with the request.
 type grpcClient struct{ client proto.KVClient }
 
 func (m *grpcClient) Put(key string, value []byte) error {
-	_, err := m.client.Put(context.Background(), &proto.PutRequest{
+	return m.PutContext(context.Background(), key, value)
+}
+
+func (m *grpcClient) Get(key string) ([]byte, error) {
+	return m.GetContext(context.Background(), key)
+}
+
+func (m *grpcClient) Delete(key string) error {
+	return m.DeleteContext(context.Background(), key)
+}
+
+func (m *grpcClient) Has(key string) (bool, error) {
+	return m.HasContext(context.Background(), key)
+}
+
+func (m *grpcClient) PutContext(ctx context.Context, key string, value []byte) error {
+	_, err := m.client.Put(ctx, &proto.PutRequest{
 		Key:   key,
 		Value: value,
 	})
-	return err
+	return fromStatus(err)
 }
 
-func (m *grpcClient) Get(key string) ([]byte, error) {
-	resp, err := m.client.Get(context.Background(), &proto.GetRequest{
+func (m *grpcClient) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return m.PutWithTTLContext(context.Background(), key, value, ttl)
+}
+
+func (m *grpcClient) PutWithTTLContext(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	millis, err := ttlMillis(ttl)
+	if err != nil {
+		return err
+	}
+	_, err = m.client.Put(ctx, &proto.PutRequest{
+		Key:       key,
+		Value:     value,
+		TtlMillis: millis,
+	})
+	return fromStatus(err)
+}
+
+func (m *grpcClient) GetContext(ctx context.Context, key string) ([]byte, error) {
+	resp, err := m.client.Get(ctx, &proto.GetRequest{
 		Key: key,
 	})
 	if err != nil {
-		return nil, err
+		return nil, fromStatus(err)
 	}
 	return resp.Value, nil
 }
 
+func (m *grpcClient) DeleteContext(ctx context.Context, key string) error {
+	_, err := m.client.Delete(ctx, &proto.DeleteRequest{
+		Key: key,
+	})
+	return fromStatus(err)
+}
+
+func (m *grpcClient) HasContext(ctx context.Context, key string) (bool, error) {
+	resp, err := m.client.Has(ctx, &proto.HasRequest{
+		Key: key,
+	})
+	if err != nil {
+		return false, fromStatus(err)
+	}
+	return resp.Exists, nil
+}
+
 // GRPCServer is the gRPC server that GRPCClient talks to.
+//
+// When the Impl is also a ContextKVStore, the request context is passed on
+// to it, so the plugin can stop work once the host is no longer waiting.
+// Otherwise a request is refused should its context already be done.
 type grpcServer struct {
 	proto.UnimplementedKVServer // enable forward-compatibility
 
 	Impl KVStore
 }
 
-func (m *grpcServer) Put(_ context.Context, req *proto.PutRequest) (*proto.Empty, error) {
-	return &proto.Empty{}, m.Impl.Put(req.Key, req.Value)
+// checkContext returns the error of the context when it is done and the Impl
+// is not a ContextKVStore, which could not see the context itself.
+func (m *grpcServer) checkContext(ctx context.Context) error {
+	if _, ok := m.Impl.(ContextKVStore); ok {
+		return nil
+	}
+	return ctx.Err()
+}
+
+// Put puts a key with a TTL using the TTLKVStore methods of the Impl, failing
+// when it does not implement them.
+func (m *grpcServer) Put(ctx context.Context, req *proto.PutRequest) (*proto.Empty, error) {
+	if err := m.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	if req.TtlMillis < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "ttl must not be negative, given %dms", req.TtlMillis)
+	} else if req.TtlMillis > 0 {
+		ttl := time.Duration(req.TtlMillis) * time.Millisecond
+		return &proto.Empty{}, toStatus(putWithTTL(ctx, m.Impl, req.Key, req.Value, ttl))
+	}
+	if impl, ok := m.Impl.(ContextKVStore); ok {
+		return &proto.Empty{}, toStatus(impl.PutContext(ctx, req.Key, req.Value))
+	}
+	return &proto.Empty{}, toStatus(m.Impl.Put(req.Key, req.Value))
+}
+
+func (m *grpcServer) Get(ctx context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
+	if err := m.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	var v []byte
+	var err error
+	if impl, ok := m.Impl.(ContextKVStore); ok {
+		v, err = impl.GetContext(ctx, req.Key)
+	} else {
+		v, err = m.Impl.Get(req.Key)
+	}
+	return &proto.GetResponse{Value: v}, toStatus(err)
 }
 
-func (m *grpcServer) Get(_ context.Context, req *proto.GetRequest) (*proto.GetResponse, error) {
-	v, err := m.Impl.Get(req.Key)
-	return &proto.GetResponse{Value: v}, err
+func (m *grpcServer) Delete(ctx context.Context, req *proto.DeleteRequest) (*proto.Empty, error) {
+	if err := m.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	if impl, ok := m.Impl.(ContextKVStore); ok {
+		return &proto.Empty{}, toStatus(impl.DeleteContext(ctx, req.Key))
+	}
+	return &proto.Empty{}, toStatus(m.Impl.Delete(req.Key))
+}
+
+func (m *grpcServer) Has(ctx context.Context, req *proto.HasRequest) (*proto.HasResponse, error) {
+	if err := m.checkContext(ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	var v bool
+	var err error
+	if impl, ok := m.Impl.(ContextKVStore); ok {
+		v, err = impl.HasContext(ctx, req.Key)
+	} else {
+		v, err = m.Impl.Has(req.Key)
+	}
+	return &proto.HasResponse{Exists: v}, toStatus(err)
 }
@@ -24,6 +24,27 @@ import (
 type KVStore interface {
 	Put(key string, value []byte) error
 	Get(key string) ([]byte, error)
+
+	// Delete removes the key from the store. Deleting a key that does not
+	// exist is not an error.
+	Delete(key string) error
+
+	// Has reports whether the key exists in the store.
+	Has(key string) (bool, error)
+}
+
+// ContextKVStore is the context-aware variant of the KVStore interface.
+//
+// The clients dispensed to host applications implement this interface, so
+// that a deadline or cancellation can be set on each request. gRPC plugins
+// may also implement it, in which case the context of the incoming request,
+// including any deadline set by the host, is passed to these methods
+// instead of those of the KVStore.
+type ContextKVStore interface {
+	PutContext(ctx context.Context, key string, value []byte) error
+	GetContext(ctx context.Context, key string) ([]byte, error)
+	DeleteContext(ctx context.Context, key string) error
+	HasContext(ctx context.Context, key string) (bool, error)
 }
 
 // KVStorePluginName is an important variable.
@@ -5,15 +5,38 @@
 package sdk
 
 import (
+	"context"
 	"net/rpc"
+	"reflect"
+	"time"
 )
 
 // RPCClient is an implementation of KVStore that talks over RPC.
+//
+// It also implements ContextKVStore, however net/rpc has no way to send a
+// deadline to the plugin, or to cancel a request, so the client only stops
+// waiting for the response once the context is done.
 type rpcClient struct {
 	client *rpc.Client
 }
 
 func (c *rpcClient) Put(key string, value []byte) error {
+	return c.PutContext(context.Background(), key, value)
+}
+
+func (c *rpcClient) Get(key string) ([]byte, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+func (c *rpcClient) Delete(key string) error {
+	return c.DeleteContext(context.Background(), key)
+}
+
+func (c *rpcClient) Has(key string) (bool, error) {
+	return c.HasContext(context.Background(), key)
+}
+
+func (c *rpcClient) PutContext(ctx context.Context, key string, value []byte) error {
 	// We don't expect a response, so we can just use interface{}
 	var resp interface{}
 
@@ -21,23 +44,78 @@ func (c *rpcClient) Put(key string, value []byte) error {
 	//
 	// `Plugin`: a go-plugin hardcoded value
 	// `Put` the method as defined on the KVStore plugin interface
-	return c.client.Call(
+	return c.call(ctx,
 		"Plugin.Put",
 		map[string]interface{}{"key": key, "value": value},
 		&resp,
 	)
 }
 
-func (c *rpcClient) Get(key string) ([]byte, error) {
+func (c *rpcClient) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	return c.PutWithTTLContext(context.Background(), key, value, ttl)
+}
+
+func (c *rpcClient) PutWithTTLContext(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	millis, err := ttlMillis(ttl)
+	if err != nil {
+		return err
+	}
+
+	var resp interface{}
+
+	// The TTL is sent in milliseconds, as for gRPC.
+	return c.call(ctx,
+		"Plugin.PutWithTTL",
+		map[string]interface{}{"key": key, "value": value, "ttl": millis},
+		&resp,
+	)
+}
+
+func (c *rpcClient) GetContext(ctx context.Context, key string) ([]byte, error) {
 	var resp []byte
 
 	// `Plugin`: a go-plugin hardcoded value
 	// `Get` the method as defined on the KVStore plugin interface
-	err := c.client.Call("Plugin.Get", key, &resp)
+	err := c.call(ctx, "Plugin.Get", key, &resp)
 
 	return resp, err
 }
 
+func (c *rpcClient) DeleteContext(ctx context.Context, key string) error {
+	// We don't expect a response, so we can just use interface{}
+	var resp interface{}
+
+	return c.call(ctx, "Plugin.Delete", key, &resp)
+}
+
+func (c *rpcClient) HasContext(ctx context.Context, key string) (bool, error) {
+	var resp bool
+
+	err := c.call(ctx, "Plugin.Has", key, &resp)
+
+	return resp, err
+}
+
+// call makes the RPC request, returning early if the context is done before
+// the plugin responds. The response is decoded into a value of its own, only
+// copied to reply once the call is done, as net/rpc may still be decoding the
+// response after returning early.
+func (c *rpcClient) call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	resp := reflect.New(reflect.TypeOf(reply).Elem())
+	call := c.client.Go(method, args, resp.Interface(), nil)
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-call.Done:
+		if call.Error != nil {
+			return fromRPCError(call.Error)
+		}
+		reflect.ValueOf(reply).Elem().Set(resp.Elem())
+		return nil
+	}
+}
+
 // RPCServer is the RPC server that RPCClient talks to, conforming to
 // the requirements of net/rpc
 type RPCServer struct {
@@ -45,11 +123,26 @@ type RPCServer struct {
 }
 
 func (s *RPCServer) Put(args map[string]interface{}, resp *interface{}) error {
-	return s.Impl.Put(args["key"].(string), args["value"].([]byte))
+	return toRPCError(s.Impl.Put(args["key"].(string), args["value"].([]byte)))
+}
+
+func (s *RPCServer) PutWithTTL(args map[string]interface{}, resp *interface{}) error {
+	ttl := time.Duration(args["ttl"].(int64)) * time.Millisecond
+	return toRPCError(putWithTTL(context.Background(), s.Impl, args["key"].(string), args["value"].([]byte), ttl))
 }
 
 func (s *RPCServer) Get(key string, resp *[]byte) error {
 	v, err := s.Impl.Get(key)
 	*resp = v
-	return err
+	return toRPCError(err)
+}
+
+func (s *RPCServer) Delete(key string, resp *interface{}) error {
+	return toRPCError(s.Impl.Delete(key))
+}
+
+func (s *RPCServer) Has(key string, resp *bool) error {
+	v, err := s.Impl.Has(key)
+	*resp = v
+	return toRPCError(err)
 }
@@ -0,0 +1,7 @@
+module github.com/mrcook/go-plugin-examples/repl
+
+go 1.20
+
+require github.com/chzyer/readline v1.5.1
+
+require golang.org/x/sys v0.7.0 // indirect
@@ -0,0 +1,233 @@
+// Package repl provides the interactive mode shared by the example host
+// applications, so a plugin can be dispensed once and then exercised with
+// many commands, rather than re-running the application for each one.
+//
+// Commands are read one line at a time, with the line history kept between
+// sessions, and tab completion of the command names and their arguments.
+// The number of calls, errors, and the time taken are recorded for each
+// command, and can be printed with WriteStats.
+//
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chzyer/readline"
+)
+
+// Command is a command accepted by the REPL.
+type Command struct {
+	Name string // the command name, e.g. "get"
+	Args string // the arguments shown in the help, e.g. "<key>"
+	Help string // a short description of the command
+
+	// MinArgs is the number of arguments the command requires. The usage is
+	// printed when fewer are given.
+	MinArgs int
+
+	// Completions are the values offered by tab completion for the first
+	// argument, e.g. the plugin names for a "switch-plugin" command.
+	Completions []string
+
+	// Run is called with the arguments following the command name, split on
+	// whitespace. A returned error is printed, and the REPL continues.
+	Run func(args []string) error
+}
+
+// Config defines the prompt and commands of the REPL.
+type Config struct {
+	Prompt   string
+	Commands []Command
+
+	// HistoryFile is where the line history is kept between sessions. The
+	// history is only kept for the session when empty.
+	HistoryFile string
+}
+
+// Stats are the statistics recorded for a command.
+type Stats struct {
+	Name   string
+	Calls  int
+	Errors int
+	Total  time.Duration // the total time taken by all calls
+}
+
+// REPL reads commands and runs them until the user exits.
+type REPL struct {
+	config   Config
+	commands map[string]Command
+	stats    map[string]*Stats
+	out      io.Writer
+}
+
+// The built-in commands, which are available in every REPL.
+const (
+	helpCommand = "help"
+	exitCommand = "exit"
+	quitCommand = "quit"
+)
+
+// New returns a REPL for the given commands.
+func New(config Config) *REPL {
+	r := &REPL{
+		config:   config,
+		commands: make(map[string]Command),
+		stats:    make(map[string]*Stats),
+		out:      os.Stdout,
+	}
+	for _, c := range config.Commands {
+		r.commands[c.Name] = c
+	}
+	return r
+}
+
+// HistoryFile returns the path of a history file named after the application
+// in the home directory of the user, e.g. "~/.kv_history". An empty path is
+// returned when there is no home directory.
+func HistoryFile(name string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "."+name+"_history")
+}
+
+// Run reads and runs commands until "exit" or "quit" is entered, or the end
+// of the input is reached. When the input is not a terminal, such as a piped
+// script, the commands are read without prompting.
+func (r *REPL) Run() error {
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:          r.config.Prompt,
+		HistoryFile:     r.config.HistoryFile,
+		AutoComplete:    r.completer(),
+		InterruptPrompt: "^C",
+		EOFPrompt:       exitCommand,
+	})
+	if err != nil {
+		return err
+	}
+	defer rl.Close()
+	r.out = rl.Stdout()
+
+	fmt.Fprintf(r.out, "Type '%s' for the available commands.\n", helpCommand)
+
+	for {
+		line, err := rl.Readline()
+		if errors.Is(err, readline.ErrInterrupt) {
+			// Ctrl+C clears the current line, or exits when it is empty.
+			if len(line) == 0 {
+				return nil
+			}
+			continue
+		} else if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name, args := fields[0], fields[1:]
+
+		switch name {
+		case exitCommand, quitCommand:
+			return nil
+		case helpCommand:
+			r.writeHelp()
+			continue
+		}
+
+		c, ok := r.commands[name]
+		if !ok {
+			fmt.Fprintf(r.out, "unknown command '%s', type '%s' for the available commands\n", name, helpCommand)
+			continue
+		} else if len(args) < c.MinArgs {
+			fmt.Fprintf(r.out, "usage: %s %s\n", c.Name, c.Args)
+			continue
+		}
+		r.run(c, args)
+	}
+}
+
+// Stats returns the statistics of each command that has been run, sorted by
+// the command name.
+func (r *REPL) Stats() []Stats {
+	stats := make([]Stats, 0, len(r.stats))
+	for _, s := range r.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// WriteStats writes the statistics of each command as a table.
+func (r *REPL) WriteStats(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMMAND\tCALLS\tERRORS\tAVG TIME")
+	for _, s := range r.Stats() {
+		avg := s.Total / time.Duration(s.Calls)
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", s.Name, s.Calls, s.Errors, avg.Round(time.Microsecond))
+	}
+	tw.Flush()
+}
+
+// run runs the command, recording its statistics.
+func (r *REPL) run(c Command, args []string) {
+	start := time.Now()
+	err := c.Run(args)
+
+	s, ok := r.stats[c.Name]
+	if !ok {
+		s = &Stats{Name: c.Name}
+		r.stats[c.Name] = s
+	}
+	s.Calls++
+	s.Total += time.Since(start)
+
+	if err != nil {
+		s.Errors++
+		fmt.Fprintln(r.out, "Error:", err.Error())
+	}
+}
+
+// writeHelp lists the commands, followed by the built-in commands.
+func (r *REPL) writeHelp() {
+	tw := tabwriter.NewWriter(r.out, 0, 0, 2, ' ', 0)
+	for _, c := range r.config.Commands {
+		fmt.Fprintf(tw, "%s %s\t%s\n", c.Name, c.Args, c.Help)
+	}
+	fmt.Fprintf(tw, "%s\t%s\n", helpCommand, "show this help")
+	fmt.Fprintf(tw, "%s\t%s\n", exitCommand, "exit the REPL, as does Ctrl+D")
+	tw.Flush()
+}
+
+// completer completes the command names, and the first argument of commands
+// with Completions.
+func (r *REPL) completer() readline.AutoCompleter {
+	var items []readline.PrefixCompleterInterface
+	for _, c := range r.config.Commands {
+		var args []readline.PrefixCompleterInterface
+		for _, arg := range c.Completions {
+			args = append(args, readline.PcItem(arg))
+		}
+		items = append(items, readline.PcItem(c.Name, args...))
+	}
+	items = append(items,
+		readline.PcItem(helpCommand),
+		readline.PcItem(exitCommand),
+		readline.PcItem(quitCommand),
+	)
+	return readline.NewPrefixCompleter(items...)
+}
@@ -0,0 +1,73 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// These errors are wrapped by the Errors returned for those kinds of failure,
+// and are used with errors.Is to check the kind of error, e.g. when converting
+// it into the error type of a plugin sdk:
+//
+//	if errors.Is(err, storage.ErrNotFound) { ... }
+var (
+	ErrNotFound         = errors.New("key not found")
+	ErrInvalidKey       = errors.New("invalid key")
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Error is the error type returned by the FileStore, with a message naming the
+// key. Err is ErrNotFound, ErrInvalidKey, or ErrPermissionDenied for those
+// kinds of failure, and otherwise the underlying error, if any.
+type Error struct {
+	Err     error
+	Message string
+}
+
+// newError returns an Error wrapping err, with a message formatted according
+// to the format specifier.
+func newError(err error, format string, a ...interface{}) *Error {
+	return &Error{Err: err, Message: fmt.Sprintf(format, a...)}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the kind of error, or the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ValidateKey returns an error wrapping ErrInvalidKey when the key is empty, or
+// contains characters that are not safe to use in a filename.
+func ValidateKey(key string) error {
+	if len(key) == 0 {
+		return newError(ErrInvalidKey, "key must not be empty")
+	}
+	if strings.ContainsAny(key, "/\\\x00") {
+		return newError(ErrInvalidKey, "key %q must not contain path separators", key)
+	}
+	return nil
+}
+
+// fileError converts an error from the os package, such as from os.ReadFile,
+// into an Error for the given key.
+func fileError(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return newError(ErrNotFound, "key %q not found", key)
+	case errors.Is(err, os.ErrPermission):
+		return newError(ErrPermissionDenied, "permission denied for key %q", key)
+	default:
+		return newError(err, "key %q: %s", key, err)
+	}
+}
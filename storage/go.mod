@@ -0,0 +1,3 @@
+module github.com/mrcook/go-plugin-examples/storage
+
+go 1.20
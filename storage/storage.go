@@ -0,0 +1,883 @@
+// Package storage stores the values of a KV store in files, for the
+// file-backed KVStore plugins of the example applications.
+//
+// The FileStore keeps the files of the keys in a single data directory, and
+// encodes the keys so they can never name a file outside that directory. It
+// may also expire keys, and keep the earlier values of each key as numbered
+// revisions.
+//
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DataDirEnv is the environment variable giving the directory that plugins
+// store their files in. Plugins inherit the environment of the host
+// application, so it can be set for all plugins when running the host.
+const DataDirEnv = "KV_DATA_DIR"
+
+// DataDir returns the directory set with the DataDirEnv environment variable,
+// or the current directory when it is not set.
+func DataDir() string {
+	if dir := os.Getenv(DataDirEnv); len(dir) > 0 {
+		return dir
+	}
+	return "."
+}
+
+// RevisionsEnv is the environment variable giving how many revisions of each
+// key the plugins keep, with zero keeping no history.
+const RevisionsEnv = "KV_REVISIONS"
+
+// DefaultRevisions is the number of revisions kept when the RevisionsEnv
+// environment variable is not set.
+const DefaultRevisions = 10
+
+// Revisions returns the number of revisions set with the RevisionsEnv
+// environment variable, or the DefaultRevisions when it is not set.
+func Revisions() (int, error) {
+	v := os.Getenv(RevisionsEnv)
+	if len(v) == 0 {
+		return DefaultRevisions, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a number of revisions, given '%s'", RevisionsEnv, v)
+	}
+	return n, nil
+}
+
+// Revision describes one of the values a key has been put with.
+type Revision struct {
+	// Number starts at 1 for the first put of the key, increasing by one
+	// with each put.
+	Number int64
+
+	// Time is when the value was put.
+	Time time.Time
+
+	// Size is the size of the value in bytes.
+	Size int64
+}
+
+// FileStore stores the value of each key in its own file, for file-backed
+// KVStore plugins.
+//
+// The files are kept in a single data directory, with their names being the
+// filename prefix followed by the encoded key. Keys are encoded such that a
+// file name can never refer to a file outside the data directory, and values
+// are written to a temporary file that only replaces the file of the key once
+// written in full, so a failed write never leaves a partial value behind.
+//
+// A key may be written with an expiry time, which is kept in a hidden file
+// alongside the file of the key. Once expired, the key is treated as missing,
+// and its files are removed by Sweep.
+//
+// When set to keep revisions, each value written is also kept in a hidden
+// revision file, up to the number of revisions, so the earlier values of a key
+// can be read, or rolled back to.
+//
+// The errors returned are Errors, which wrap ErrNotFound for a missing key.
+type FileStore struct {
+	dir       string
+	prefix    string
+	revisions int
+}
+
+// NewFileStore returns a FileStore for the files in the directory with the
+// filename prefix, e.g. "kv_grpc_". The directory is created when it does not
+// exist.
+func NewFileStore(dir, prefix string) (*FileStore, error) {
+	if strings.ContainsAny(prefix, "/\\\x00") || strings.HasPrefix(prefix, ".") {
+		return nil, fmt.Errorf("filename prefix %q must not contain path separators, or start with a dot", prefix)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("creating the data directory: %w", err)
+	}
+	return &FileStore{dir: dir, prefix: prefix}, nil
+}
+
+// SetRevisions sets how many revisions of each key are kept, with zero, the
+// default, keeping none. It must be called before the store is used.
+func (s *FileStore) SetRevisions(n int) {
+	s.revisions = n
+}
+
+// Dir returns the data directory of the files.
+func (s *FileStore) Dir() string {
+	return s.dir
+}
+
+// Path returns the path of the file for the key, which is always within the
+// data directory. The key is checked with ValidateKey.
+func (s *FileStore) Path(key string) (string, error) {
+	if err := ValidateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, s.prefix+encodeKey(key)), nil
+}
+
+// Key returns the key of the file with the given name, and whether the name
+// is that of a key file of the store.
+func (s *FileStore) Key(name string) (string, bool) {
+	if !strings.HasPrefix(name, s.prefix) {
+		return "", false
+	}
+	return decodeKey(strings.TrimPrefix(name, s.prefix))
+}
+
+// ReadFile returns the contents of the file for the key.
+func (s *FileStore) ReadFile(key string) ([]byte, error) {
+	path, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkExpired(key); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	return data, fileError(key, err)
+}
+
+// Open opens the file for the key, for reading.
+func (s *FileStore) Open(key string) (*os.File, error) {
+	path, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkExpired(key); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	return f, fileError(key, err)
+}
+
+// WriteFile replaces the contents of the file for the key with the data. The
+// key never expires, even if it was written with an expiry time before.
+func (s *FileStore) WriteFile(key string, data []byte) error {
+	return s.WriteFileWithExpiry(key, data, time.Time{})
+}
+
+// WriteFileWithExpiry replaces the contents of the file for the key with the
+// data, with the key expiring at the given time. A zero time is the same as
+// WriteFile.
+func (s *FileStore) WriteFileWithExpiry(key string, data []byte, expires time.Time) error {
+	f, err := s.Create(key)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+	f.expires = expires
+
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// Create returns a PendingFile, for writing the contents of the file for the
+// key, e.g. as they are streamed from the host application. Once committed,
+// the key never expires.
+func (s *FileStore) Create(key string) (*PendingFile, error) {
+	path, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Key files never start with a dot, so the temporary file is not taken
+	// for a key file when listing the keys.
+	f, err := os.CreateTemp(s.dir, "."+s.prefix+"*.tmp")
+	if err != nil {
+		return nil, fileError(key, err)
+	}
+	return &PendingFile{store: s, key: key, path: path, file: f}, nil
+}
+
+// Remove removes the file for the key, along with its expiry time and
+// revisions. Removing the file of a key that does not exist is not an error.
+func (s *FileStore) Remove(key string) error {
+	path, err := s.Path(key)
+	if err != nil {
+		return err
+	}
+	if err := removeFile(path); err != nil {
+		return fileError(key, err)
+	}
+	if err := removeFile(s.expiryPath(key)); err != nil {
+		return fileError(key, err)
+	}
+	numbers, err := s.revisionNumbers(key)
+	if err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		if err := removeFile(s.revisionPath(key, number)); err != nil {
+			return fileError(key, err)
+		}
+	}
+	return nil
+}
+
+// Exists reports whether the file for the key exists, and has not expired.
+func (s *FileStore) Exists(key string) (bool, error) {
+	path, err := s.Path(key)
+	if err != nil {
+		return false, err
+	}
+	if expired, err := s.Expired(key); err != nil || expired {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fileError(key, err)
+	}
+	return true, nil
+}
+
+// Keys returns the sorted keys of the files in the data directory, which
+// start with the key prefix. Expired keys are left out.
+func (s *FileStore) Keys(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, newError(nil, "listing keys: %s", err)
+	}
+
+	// Only the keys with an expiry file need their expiry time read.
+	var keys []string
+	expiring := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if key, ok := s.Key(entry.Name()); ok && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		} else if key, ok := s.expiryKey(entry.Name()); ok {
+			expiring[key] = true
+		}
+	}
+
+	live := keys[:0]
+	for _, key := range keys {
+		if expiring[key] {
+			if expired, err := s.Expired(key); err != nil {
+				return nil, err
+			} else if expired {
+				continue
+			}
+		}
+		live = append(live, key)
+	}
+	sort.Strings(live)
+	return live, nil
+}
+
+// Expiry returns the time the key expires, or the zero time when the key
+// never expires.
+func (s *FileStore) Expiry(key string) (time.Time, error) {
+	if err := ValidateKey(key); err != nil {
+		return time.Time{}, err
+	}
+	data, err := os.ReadFile(s.expiryPath(key))
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, fileError(key, err)
+	}
+	expires, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
+	if err != nil {
+		return time.Time{}, newError(nil, "key %q: invalid expiry time: %s", key, err)
+	}
+	return expires, nil
+}
+
+// Expired reports whether the key has an expiry time which has passed.
+func (s *FileStore) Expired(key string) (bool, error) {
+	expires, err := s.Expiry(key)
+	if err != nil {
+		return false, err
+	}
+	return !expires.IsZero() && !time.Now().Before(expires), nil
+}
+
+// Sweep removes the files of the expired keys, returning how many keys were
+// removed.
+//
+// A key written again while it is being removed may also be removed, as the
+// data directory can be shared with other processes, so no lock is held.
+func (s *FileStore) Sweep() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, newError(nil, "sweeping keys: %s", err)
+	}
+
+	var removed int
+	for _, entry := range entries {
+		key, ok := s.expiryKey(entry.Name())
+		if !ok {
+			continue
+		}
+		if expired, err := s.Expired(key); err != nil {
+			return removed, err
+		} else if !expired {
+			continue
+		}
+		if err := s.Remove(key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// SweepEvery calls Sweep at each interval, until the context is done, so the
+// files of expired keys do not build up in the data directory. Failures are
+// written to stderr, which go-plugin passes on to the host application's log.
+func (s *FileStore) SweepEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Sweep(); err != nil {
+				fmt.Fprintln(os.Stderr, "sweeping expired keys:", err)
+			}
+		}
+	}
+}
+
+// History returns the revisions kept for the key, oldest first, with the last
+// being the current value. A key written while no revisions were kept may have
+// none.
+func (s *FileStore) History(key string) ([]Revision, error) {
+	if exists, err := s.Exists(key); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, newError(ErrNotFound, "key %q not found", key)
+	}
+
+	numbers, err := s.revisionNumbers(key)
+	if err != nil {
+		return nil, err
+	}
+	revisions := make([]Revision, 0, len(numbers))
+	for _, number := range numbers {
+		info, err := os.Stat(s.revisionPath(key, number))
+		if os.IsNotExist(err) {
+			// removed by another process since the directory was read.
+			continue
+		} else if err != nil {
+			return nil, fileError(key, err)
+		}
+		revisions = append(revisions, Revision{Number: number, Time: info.ModTime(), Size: info.Size()})
+	}
+	return revisions, nil
+}
+
+// ReadRevision returns the contents of the file for the key at the revision.
+func (s *FileStore) ReadRevision(key string, number int64) ([]byte, error) {
+	f, err := s.OpenRevision(key, number)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	return data, fileError(key, err)
+}
+
+// OpenRevision opens the file for the key at the revision, for reading.
+func (s *FileStore) OpenRevision(key string, number int64) (*os.File, error) {
+	if err := ValidateKey(key); err != nil {
+		return nil, err
+	}
+	if err := s.checkExpired(key); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(s.revisionPath(key, number))
+	if os.IsNotExist(err) {
+		return nil, newError(ErrNotFound, "revision %d of key %q not found", number, key)
+	}
+	return f, fileError(key, err)
+}
+
+// Rollback writes the contents of the key at the revision as a new revision,
+// so the history of the key is kept. The key never expires once rolled back.
+func (s *FileStore) Rollback(key string, number int64) error {
+	r, err := s.OpenRevision(key, number)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := s.Create(key)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return fileError(key, err)
+	}
+	return f.Commit()
+}
+
+// Snapshot returns the state of the files of the key: its value, expiry time,
+// and revisions, so the writes made to the key since can be undone. The files
+// are hard linked where possible, so the values are not copied.
+func (s *FileStore) Snapshot(key string) (*Snapshot, error) {
+	path, err := s.Path(key)
+	if err != nil {
+		return nil, err
+	}
+	numbers, err := s.revisionNumbers(key)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := []string{path, s.expiryPath(key)}
+	for _, number := range numbers {
+		paths = append(paths, s.revisionPath(key, number))
+	}
+
+	snap := &Snapshot{store: s, key: key, path: path, links: make(map[string]string)}
+	for _, p := range paths {
+		link, err := s.linkTemp(p)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			snap.Discard()
+			return nil, fileError(key, err)
+		}
+		snap.links[p] = link
+	}
+	return snap, nil
+}
+
+// addRevision keeps the contents of the named file as the next revision of
+// the key, returning its path. The file is hard linked where possible, so the
+// contents are not stored twice.
+//
+// Another process writing the key at the same time may use the same revision
+// number, in which case the last of them is kept.
+func (s *FileStore) addRevision(key, name string) (string, error) {
+	numbers, err := s.revisionNumbers(key)
+	if err != nil {
+		return "", err
+	}
+	next := int64(1)
+	if len(numbers) > 0 {
+		next = numbers[len(numbers)-1] + 1
+	}
+
+	path := s.revisionPath(key, next)
+	if err := os.Link(name, path); err != nil {
+		if err := copyFile(s.dir, "."+s.prefix+"*.tmp", name, path); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// pruneRevisions removes the oldest revisions of the key, beyond the number
+// kept. The key has already been written, so a revision that can not be
+// removed is left for the next write to remove.
+func (s *FileStore) pruneRevisions(key string) {
+	numbers, err := s.revisionNumbers(key)
+	if err != nil {
+		return
+	}
+	for len(numbers) > s.revisions {
+		removeFile(s.revisionPath(key, numbers[0]))
+		numbers = numbers[1:]
+	}
+}
+
+// revisionNumbers returns the sorted numbers of the revisions of the key.
+func (s *FileStore) revisionNumbers(key string) ([]int64, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, newError(nil, "reading revisions: %s", err)
+	}
+
+	namePrefix := "." + s.prefix + encodeKey(key) + "."
+	var numbers []int64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, namePrefix) || !strings.HasSuffix(name, ".rev") {
+			continue
+		}
+		number, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, namePrefix), ".rev"), 10, 64)
+		if err == nil && number > 0 {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers, nil
+}
+
+// revisionPath returns the path of the file holding the revision of the key.
+// Encoded keys never contain a dot followed by only digits and ".rev", so no
+// revision file of one key is taken for that of another.
+func (s *FileStore) revisionPath(key string, number int64) string {
+	return filepath.Join(s.dir, "."+s.prefix+encodeKey(key)+"."+strconv.FormatInt(number, 10)+".rev")
+}
+
+// checkExpired returns an ErrNotFound error when the key has expired.
+func (s *FileStore) checkExpired(key string) error {
+	if expired, err := s.Expired(key); err != nil {
+		return err
+	} else if expired {
+		return newError(ErrNotFound, "key %q not found", key)
+	}
+	return nil
+}
+
+// expiryPath returns the path of the file holding the expiry time of the key.
+// It starts with a dot, like the temporary files, so it is never taken for a
+// key file.
+func (s *FileStore) expiryPath(key string) string {
+	return filepath.Join(s.dir, "."+s.prefix+encodeKey(key)+".expires")
+}
+
+// expiryKey returns the key of the expiry file with the given name, and
+// whether the name is that of an expiry file of the store.
+func (s *FileStore) expiryKey(name string) (string, bool) {
+	if !strings.HasPrefix(name, "."+s.prefix) || !strings.HasSuffix(name, ".expires") {
+		return "", false
+	}
+	return decodeKey(strings.TrimSuffix(strings.TrimPrefix(name, "."+s.prefix), ".expires"))
+}
+
+// writeExpiry replaces the expiry time of the key, or removes it for the zero
+// time.
+func (s *FileStore) writeExpiry(key string, expires time.Time) error {
+	if expires.IsZero() {
+		return removeFile(s.expiryPath(key))
+	}
+
+	f, err := os.CreateTemp(s.dir, "."+s.prefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(expires.UTC().Format(time.RFC3339Nano) + "\n")
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.expiryPath(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// restoreExpiry puts back the expiry file of the key from the link to it, or
+// removes the expiry file when the key had none. This is best effort, as the
+// write being undone has already failed.
+func (s *FileStore) restoreExpiry(key, link string) {
+	path := s.expiryPath(key)
+	if len(link) == 0 {
+		removeFile(path)
+		return
+	}
+
+	// Renaming a link over the file it links to does nothing, leaving the
+	// link to be removed by the caller.
+	os.Rename(link, path)
+}
+
+// linkTemp links the file at path to a new temporary file in the data
+// directory, or copies it where hard links are not supported, returning the
+// path of the temporary file.
+func (s *FileStore) linkTemp(path string) (string, error) {
+	f, err := os.CreateTemp(s.dir, "."+s.prefix+"*.tmp")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+
+	// The temporary file only reserves the name, so is replaced by the link.
+	name := f.Name()
+	os.Remove(name)
+	if err := os.Link(path, name); err != nil {
+		if err := copyFile(s.dir, "."+s.prefix+"*.tmp", path, name); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// copyFile copies the file at src to dst, using a temporary file in the
+// directory, which replaces dst once written in full.
+func copyFile(dir, pattern, src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// removeFile removes the file, with a file that does not exist not being an
+// error.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PendingFile is a file being written for a key, which only replaces the file
+// of the key once committed. Abort should be deferred, to remove the file when
+// the write fails.
+type PendingFile struct {
+	store   *FileStore
+	key     string
+	path    string
+	file    *os.File
+	expires time.Time
+	done    bool
+}
+
+func (f *PendingFile) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	return n, fileError(f.key, err)
+}
+
+// Commit replaces the file of the key with the contents written, keeping them
+// as a new revision when the store keeps revisions. The expiry time is written
+// first, so the new contents are never visible with the expiry time of the
+// contents they replace, and is put back should the commit then fail.
+func (f *PendingFile) Commit() error {
+	if f.done {
+		return newError(nil, "key %q: the file was already committed or aborted", f.key)
+	}
+	f.done = true
+
+	err := f.file.Sync()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.file.Name(), 0644)
+	}
+	var oldExpiry string
+	if err == nil {
+		oldExpiry, err = f.store.linkTemp(f.store.expiryPath(f.key))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	}
+	expiryWritten := err == nil
+	if err == nil {
+		err = f.store.writeExpiry(f.key, f.expires)
+	}
+	var revisionPath string
+	if err == nil && f.store.revisions > 0 {
+		revisionPath, err = f.store.addRevision(f.key, f.file.Name())
+	}
+	if err == nil {
+		err = os.Rename(f.file.Name(), f.path)
+	}
+	if err != nil {
+		os.Remove(f.file.Name())
+		if len(revisionPath) > 0 {
+			os.Remove(revisionPath)
+		}
+		if expiryWritten {
+			f.store.restoreExpiry(f.key, oldExpiry)
+		}
+	} else if len(revisionPath) > 0 {
+		f.store.pruneRevisions(f.key)
+	}
+	if len(oldExpiry) > 0 {
+		os.Remove(oldExpiry)
+	}
+	return fileError(f.key, err)
+}
+
+// Abort removes the file, leaving the file of the key unchanged. Once the file
+// has been committed, Abort does nothing.
+func (f *PendingFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
+
+// Snapshot is the state of the files of a key, taken by FileStore.Snapshot, for
+// undoing the writes made to the key since, e.g. by a transaction that failed
+// part way. Either Restore or Discard must be called once done with it.
+type Snapshot struct {
+	store *FileStore
+	key   string
+	path  string
+
+	// links maps the path of each file of the key that existed to the
+	// temporary file linked to it.
+	links map[string]string
+	done  bool
+}
+
+// Restore puts the files of the key back as they were when the snapshot was
+// taken, removing any written since, so the key has the same value, expiry
+// time, and revisions as before, with no trace of the writes being left. Every
+// file is restored, even once one fails.
+func (snap *Snapshot) Restore() error {
+	if snap.done {
+		return newError(nil, "key %q: the snapshot was already restored or discarded", snap.key)
+	}
+	snap.done = true
+	s := snap.store
+
+	// The file of a key that did not exist is removed first, so the key is
+	// never seen with the expiry time of another value.
+	var errs []error
+	if _, ok := snap.links[snap.path]; !ok {
+		errs = append(errs, removeFile(snap.path))
+	}
+
+	numbers, err := s.revisionNumbers(snap.key)
+	errs = append(errs, err)
+	paths := map[string]bool{s.expiryPath(snap.key): true}
+	for _, number := range numbers {
+		paths[s.revisionPath(snap.key, number)] = true
+	}
+	for p := range snap.links {
+		paths[p] = p != snap.path
+	}
+	for p, restore := range paths {
+		if restore {
+			errs = append(errs, snap.restoreFile(p))
+		}
+	}
+
+	// The value is restored last, once its expiry time is in place.
+	if _, ok := snap.links[snap.path]; ok {
+		errs = append(errs, snap.restoreFile(snap.path))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return newError(err, "key %q: restoring the files: %s", snap.key, err)
+	}
+	return nil
+}
+
+// restoreFile replaces the file at the path with the file linked to it, or
+// removes it when it did not exist.
+func (snap *Snapshot) restoreFile(path string) error {
+	link, ok := snap.links[path]
+	if !ok {
+		return removeFile(path)
+	}
+	if err := os.Rename(link, path); err != nil {
+		return err
+	}
+
+	// Renaming a link over the file it links to does nothing, leaving the
+	// link in place.
+	return removeFile(link)
+}
+
+// Discard removes the snapshot, leaving the files of the key as they are. Once
+// the snapshot has been restored, Discard does nothing.
+func (snap *Snapshot) Discard() {
+	if snap.done {
+		return
+	}
+	snap.done = true
+	for _, link := range snap.links {
+		os.Remove(link)
+	}
+}
+
+// encodeKey escapes the bytes of the key that are not safe to use in a file
+// name on every OS, along with a leading dot, so no key can name "." or "..",
+// or a hidden file. Common characters are left as is, so the file names stay
+// readable, e.g. "hello-world.txt".
+func encodeKey(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if isSafeKeyByte(c) && !(i == 0 && c == '.') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// decodeKey returns the key of an encoded file name, and whether the name is
+// the encoding of a key. Only names as written by encodeKey are accepted, so
+// no two files have the same key.
+func decodeKey(name string) (string, bool) {
+	if len(name) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '%' {
+			b.WriteByte(name[i])
+			continue
+		}
+		if i+2 >= len(name) {
+			return "", false
+		}
+		v, err := strconv.ParseUint(name[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(byte(v))
+		i += 2
+	}
+
+	key := b.String()
+	return key, encodeKey(key) == name
+}
+
+func isSafeKeyByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.'
+}
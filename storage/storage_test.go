@@ -0,0 +1,235 @@
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T, revisions int) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(t.TempDir(), "kv_test_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetRevisions(revisions)
+	return s
+}
+
+func TestEncodeKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		name string
+	}{
+		{"hello", "hello"},
+		{"a b", "a%20b"},
+		{"../etc", "%2E.%2Fetc"},
+		{"..", "%2E."},
+		{".hidden", "%2Ehidden"},
+		{"100%", "100%25"},
+		{"%2E", "%252E"},
+		{"a.expires", "a.expires"},
+		{"a.1.rev", "a.1.rev"},
+		{"a.tmp", "a.tmp"},
+		{"é", "%C3%A9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			name := encodeKey(tt.key)
+			if name != tt.name {
+				t.Errorf("encodeKey(%q) = %q, want %q", tt.key, name, tt.name)
+			}
+			if strings.ContainsAny(name, "/\\") {
+				t.Errorf("encodeKey(%q) = %q, contains a path separator", tt.key, name)
+			}
+			key, ok := decodeKey(name)
+			if !ok || key != tt.key {
+				t.Errorf("decodeKey(%q) = %q, %v, want %q, true", name, key, ok, tt.key)
+			}
+		})
+	}
+}
+
+func TestDecodeKeyRejectsOtherEncodings(t *testing.T) {
+	// Only the names written by encodeKey are keys, so no two files can have
+	// the same key.
+	for _, name := range []string{"", "%", "%2", "%zz", "%2e.", ".hidden", "..", "%61", "a%2f"} {
+		if key, ok := decodeKey(name); ok {
+			t.Errorf("decodeKey(%q) = %q, true, want false", name, key)
+		}
+	}
+}
+
+func TestFileStoreHiddenNames(t *testing.T) {
+	s := newTestStore(t, 3)
+	keys := []string{"a", "a.expires", "a.1.rev", "a.2", "a.tmp", ".a", ".."}
+	for _, key := range keys {
+		for i := 0; i < 2; i++ {
+			if err := s.WriteFileWithExpiry(key, []byte(key), time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("WriteFileWithExpiry(%q) error = %v", key, err)
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(s.Dir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		_, isKey := s.Key(name)
+		_, isExpiry := s.expiryKey(name)
+		if isKey == isExpiry && !strings.HasSuffix(name, ".rev") {
+			t.Errorf("file %q is a key file: %v, and an expiry file: %v", name, isKey, isExpiry)
+		}
+	}
+
+	got, err := s.Keys("")
+	if err != nil {
+		t.Fatalf("Keys() error = %v", err)
+	}
+	want := []string{"..", ".a", "a", "a.1.rev", "a.2", "a.expires", "a.tmp"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Keys() = %q, want %q", got, want)
+	}
+
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			value, err := s.ReadFile(key)
+			if err != nil || string(value) != key {
+				t.Errorf("ReadFile() = %q, %v, want %q", value, err, key)
+			}
+			if numbers, err := s.revisionNumbers(key); err != nil || !reflect.DeepEqual(numbers, []int64{1, 2}) {
+				t.Errorf("revisionNumbers() = %v, %v, want [1 2]", numbers, err)
+			}
+			if expires, err := s.Expiry(key); err != nil || expires.IsZero() {
+				t.Errorf("Expiry() = %v, %v, want an expiry time", expires, err)
+			}
+		})
+	}
+}
+
+func TestFileStoreErrors(t *testing.T) {
+	s := newTestStore(t, 0)
+	tests := []struct {
+		key  string
+		want error
+	}{
+		{"", ErrInvalidKey},
+		{"a/b", ErrInvalidKey},
+		{"missing", ErrNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := s.ReadFile(tt.key); !errors.Is(err, tt.want) {
+			t.Errorf("ReadFile(%q) error = %v, want %v", tt.key, err, tt.want)
+		}
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	s := newTestStore(t, 3)
+	if err := s.WriteFileWithExpiry("a", []byte("one"), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	before := dirFiles(t, s.Dir())
+
+	snapA, err := s.Snapshot("a")
+	if err != nil {
+		t.Fatalf("Snapshot(a) error = %v", err)
+	}
+	snapB, err := s.Snapshot("b")
+	if err != nil {
+		t.Fatalf("Snapshot(b) error = %v", err)
+	}
+	if err := s.WriteFile("a", []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteFile("b", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := snapA.Restore(); err != nil {
+		t.Fatalf("Restore(a) error = %v", err)
+	}
+	if err := snapB.Restore(); err != nil {
+		t.Fatalf("Restore(b) error = %v", err)
+	}
+	if err := snapA.Restore(); err == nil {
+		t.Error("Restore(a) again, want an error")
+	}
+
+	if after := dirFiles(t, s.Dir()); !reflect.DeepEqual(after, before) {
+		t.Errorf("files after Restore() = %v, want %v", after, before)
+	}
+}
+
+// dirFiles returns the contents of each file in the directory, by name.
+func dirFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
+
+func TestFailedWriteKeepsExpiry(t *testing.T) {
+	s := newTestStore(t, 3)
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	if err := s.WriteFileWithExpiry("a", []byte("one"), expires); err != nil {
+		t.Fatal(err)
+	}
+
+	// A non-empty directory in place of the file of the key can not be
+	// replaced, so the writes fail when renaming the file written.
+	path, err := s.Path("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "child"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	before := dirFiles(t, s.Dir())
+
+	tests := []struct {
+		name    string
+		expires time.Time
+	}{
+		{"with a ttl", time.Now().Add(time.Minute)},
+		{"without a ttl", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.WriteFileWithExpiry("a", []byte("two"), tt.expires); err == nil {
+				t.Fatal("WriteFileWithExpiry() error = nil, want an error")
+			}
+			if got, err := s.Expiry("a"); err != nil || !got.Equal(expires) {
+				t.Errorf("Expiry() = %v, %v, want %v", got, err, expires)
+			}
+			if after := dirFiles(t, s.Dir()); !reflect.DeepEqual(after, before) {
+				t.Errorf("files after a failed write = %v, want %v", after, before)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Package supervisor keeps a plugin running for long-running host applications.
+//
+// A Supervisor wraps the plugin.Client of a single plugin. When the plugin
+// exits, for example because it crashed, the Supervisor restarts it, waiting
+// with exponential backoff between restarts, and dispenses the plugin
+// interface again. Calls made using Do are retried after a restart, so a crash
+// is transparent to the caller.
+//
+// Copyright (c) Michael R. Cook.
+// SPDX-License-Identifier: MPL-2.0
+package supervisor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+)
+
+// Default values used for any Config fields that are not set.
+const (
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+	DefaultMaxRetries = 3
+)
+
+// ErrKilled is returned once the Supervisor has been killed.
+var ErrKilled = errors.New("supervisor: plugin has been killed")
+
+// Config defines how the plugin is started, and restarted.
+type Config struct {
+	// ClientConfig returns the configuration for a new plugin client. It is
+	// called each time the plugin is started, as the Cmd and SecureConfig of
+	// a plugin.ClientConfig can only be used once.
+	ClientConfig func() *plugin.ClientConfig
+
+	// Dispense requests the plugin interface from a newly started client,
+	// e.g. by calling client.Client() followed by Dispense(pluginName).
+	Dispense func(client *plugin.Client) (interface{}, error)
+
+	// MinBackoff is how long to wait before restarting the plugin. The wait
+	// doubles for each restart, up to MaxBackoff, until the plugin has stayed
+	// up for longer than MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// MaxRetries is how many times a call made using Do is retried after the
+	// plugin has been restarted.
+	MaxRetries int
+
+	// Logger is used to log restarts. Logs are discarded when nil.
+	Logger hclog.Logger
+}
+
+// Supervisor starts a plugin and restarts it whenever it exits.
+//
+// It is safe for concurrent use. While the plugin is being restarted, other
+// calls wait for the restart to complete.
+type Supervisor struct {
+	config Config
+
+	mu        sync.Mutex
+	client    *plugin.Client
+	raw       interface{}
+	startedAt time.Time
+	attempts  int // restarts since the plugin last stayed up, used for the backoff
+	restarts  int // total number of restarts
+	killed    bool
+}
+
+// New starts the plugin and returns a Supervisor for it. An error is returned
+// when the plugin can't be started, as it is not restarted until it has been
+// running at least once.
+func New(config Config) (*Supervisor, error) {
+	if config.ClientConfig == nil || config.Dispense == nil {
+		return nil, errors.New("supervisor: ClientConfig and Dispense must be set")
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = DefaultMinBackoff
+	}
+	if config.MaxBackoff < config.MinBackoff {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+	if config.Logger == nil {
+		config.Logger = hclog.NewNullLogger()
+	}
+
+	s := &Supervisor{config: config}
+	if err := s.start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Dispense returns the plugin interface. When the plugin has exited it is
+// restarted first, and the interface of the new plugin is returned.
+func (s *Supervisor) Dispense(ctx context.Context) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.killed {
+		return nil, ErrKilled
+	}
+	if s.client != nil && !s.client.Exited() {
+		return s.raw, nil
+	}
+	if err := s.restart(ctx); err != nil {
+		return nil, err
+	}
+	return s.raw, nil
+}
+
+// Do calls fn with the plugin interface. When fn returns an error and the
+// plugin has failed, the plugin is restarted and fn is called again, up to
+// MaxRetries times.
+//
+// The plugin may fail part way through handling a call, so only calls that
+// are safe to repeat, such as reading a value, should be made using Do.
+func (s *Supervisor) Do(ctx context.Context, fn func(raw interface{}) error) error {
+	for retries := 0; ; retries++ {
+		raw, err := s.Dispense(ctx)
+		if err != nil {
+			return err
+		}
+		err = fn(raw)
+		if err == nil || retries >= s.config.MaxRetries || ctx.Err() != nil || !s.failed() {
+			return err
+		}
+		s.config.Logger.Warn("plugin failed, retrying call", "error", err, "retry", retries+1)
+	}
+}
+
+// Restarts returns how many times the plugin has been restarted.
+func (s *Supervisor) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// Kill stops the plugin. It is not restarted again.
+func (s *Supervisor) Kill() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.killed = true
+	if s.client != nil {
+		s.client.Kill()
+	}
+}
+
+// failed reports whether the plugin has exited, or no longer responds. A
+// plugin that does not respond is killed, so that it is restarted.
+func (s *Supervisor) failed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client == nil || s.client.Exited() {
+		return true
+	}
+
+	// The plugin process may still be shutting down, so check the connection.
+	rpcClient, err := s.client.Client()
+	if err == nil {
+		err = rpcClient.Ping()
+	}
+	if err != nil {
+		s.client.Kill()
+		return true
+	}
+	return false
+}
+
+// start launches the plugin and dispenses its interface. s.mu must be held,
+// except in New.
+func (s *Supervisor) start() error {
+	client := plugin.NewClient(s.config.ClientConfig())
+	raw, err := s.config.Dispense(client)
+	if err != nil {
+		client.Kill()
+		return err
+	}
+
+	s.client = client
+	s.raw = raw
+	s.startedAt = time.Now()
+	return nil
+}
+
+// restart kills the exited plugin and starts a new one, waiting before each
+// attempt with exponential backoff. s.mu must be held.
+func (s *Supervisor) restart(ctx context.Context) error {
+	if s.client != nil {
+		s.client.Kill()
+		s.client = nil
+	}
+
+	// A plugin that stayed up for a while is not crashing on startup, so the
+	// backoff starts again from the minimum.
+	if time.Since(s.startedAt) > s.config.MaxBackoff {
+		s.attempts = 0
+	}
+
+	for {
+		wait := s.backoff()
+		s.attempts++
+		s.config.Logger.Info("restarting plugin", "wait", wait, "attempt", s.attempts)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		if err := s.start(); err != nil {
+			s.config.Logger.Error("failed to restart plugin", "error", err)
+			continue
+		}
+		s.restarts++
+		return nil
+	}
+}
+
+// backoff returns how long to wait before the next restart attempt.
+func (s *Supervisor) backoff() time.Duration {
+	wait := s.config.MinBackoff
+	for i := 0; i < s.attempts && wait < s.config.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > s.config.MaxBackoff {
+		wait = s.config.MaxBackoff
+	}
+	return wait
+}